@@ -0,0 +1,8 @@
+module github.com/mind1949/wxpay_demo
+
+go 1.21
+
+require (
+	golang.org/x/crypto v0.28.0
+	golang.org/x/text v0.19.0
+)
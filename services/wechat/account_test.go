@@ -0,0 +1,63 @@
+package wechat
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAccountRedacted(t *testing.T) {
+	certPEM, keyPEM := newTestCertPEM(t)
+	a := NewAccountWithPEM(testAppID, testMchID, testAPIKey, certPEM, keyPEM, false)
+	c := NewClient(a)
+	for _, s := range []string{fmt.Sprint(a), fmt.Sprintf("%+v", a), fmt.Sprintf("%#v", a), fmt.Sprint(c), fmt.Sprintf("%#v", c)} {
+		if strings.Contains(s, testAPIKey) || strings.Contains(s, "PRIVATE KEY") {
+			t.Errorf("secret leaked: %s", s)
+		}
+		if !strings.Contains(s, testMchID) || !strings.Contains(s, maskKey(testAPIKey)) {
+			t.Errorf("missing account info: %s", s)
+		}
+	}
+}
+
+func TestMaskKey(t *testing.T) {
+	for key, want := range map[string]string{"": "", "abcd": "****", "abcdef": "ab**ef"} {
+		if got := maskKey(key); got != want {
+			t.Errorf("maskKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestNewAccountChecked(t *testing.T) {
+	if _, err := NewAccountChecked(testAppID, testMchID, testAPIKey, false); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name                 string
+		appID, mchID, apiKey string
+	}{
+		{"appID prefix", "xx2421b1c4370ec43b", testMchID, testAPIKey},
+		{"empty appID", "", testMchID, testAPIKey},
+		{"mchID letters", testAppID, "1000a100", testAPIKey},
+		{"empty mchID", testAppID, "", testAPIKey},
+		{"short apiKey", testAppID, testMchID, testAPIKey[:31]},
+		{"long apiKey", testAppID, testMchID, testAPIKey + "0"},
+	}
+	for _, tt := range tests {
+		if a, err := NewAccountChecked(tt.appID, tt.mchID, tt.apiKey, false); err == nil || a != nil {
+			t.Errorf("%s: NewAccountChecked() = %v, %v, want error", tt.name, a, err)
+		} else if strings.Contains(err.Error(), testAPIKey[:31]) {
+			t.Errorf("%s: error leaks the apiKey: %v", tt.name, err)
+		}
+	}
+}
+
+func TestAccountGetters(t *testing.T) {
+	a := NewAccount(testAppID, testMchID, testAPIKey, true)
+	if a.AppID() != testAppID || a.MchID() != testMchID || !a.IsSandbox() {
+		t.Errorf("getters = %s/%s/%t", a.AppID(), a.MchID(), a.IsSandbox())
+	}
+	if NewAccount(testAppID, testMchID, testAPIKey, false).IsSandbox() {
+		t.Error("IsSandbox() = true for a production account")
+	}
+}
@@ -0,0 +1,51 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/aes"
+	"errors"
+)
+
+// 使用AES-ECB解密并去除PKCS#7填充, key的长度决定AES-128/192/256
+// 退款结果通知等接口使用AES-256-ECB, 秘钥为apiKey的MD5值(32位小写)
+func DecryptAESECB(ciphertext, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("wechat: invalid ciphertext length")
+	}
+	// ECB模式逐块解密
+	plaintext := make([]byte, len(ciphertext))
+	for i := 0; i < len(ciphertext); i += aes.BlockSize {
+		block.Decrypt(plaintext[i:i+aes.BlockSize], ciphertext[i:i+aes.BlockSize])
+	}
+	// 去除PKCS#7填充
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errors.New("wechat: invalid PKCS#7 padding")
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("wechat: invalid PKCS#7 padding")
+		}
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// 使用PKCS#7填充后以AES-ECB加密, 与DecryptAESECB对应
+func EncryptAESECB(plaintext, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	data := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	// ECB模式逐块加密
+	ciphertext := make([]byte, len(data))
+	for i := 0; i < len(data); i += aes.BlockSize {
+		block.Encrypt(ciphertext[i:i+aes.BlockSize], data[i:i+aes.BlockSize])
+	}
+	return ciphertext, nil
+}
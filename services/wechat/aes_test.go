@@ -0,0 +1,65 @@
+package wechat
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestAESECB(t *testing.T) {
+	// FIPS-197附录C.3的AES-256测试向量
+	key, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	plaintext, _ := hex.DecodeString("00112233445566778899aabbccddeeff")
+	ciphertext, err := EncryptAESECB(plaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 整块的明文也会追加一整块填充
+	if len(ciphertext) != 32 || hex.EncodeToString(ciphertext[:16]) != "8ea2b7ca516745bfeafc49904b496089" {
+		t.Errorf("EncryptAESECB() = %x", ciphertext)
+	}
+	got, err := DecryptAESECB(ciphertext, key)
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Errorf("DecryptAESECB() = %x, %v, want %x", got, err, plaintext)
+	}
+}
+
+func TestAESECBRoundTrip(t *testing.T) {
+	key := []byte("192006250b4c09247ec02edce69f6a2d")
+	for _, n := range []int{0, 1, 15, 16, 17, 100} {
+		plaintext := bytes.Repeat([]byte{'x'}, n)
+		ciphertext, err := EncryptAESECB(plaintext, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecryptAESECB(ciphertext, key)
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("%d bytes: round trip = %q, %v", n, got, err)
+		}
+	}
+}
+
+func TestDecryptAESECBErrors(t *testing.T) {
+	key := []byte("192006250b4c09247ec02edce69f6a2d")
+	valid, _ := EncryptAESECB([]byte("<root></root>"), key)
+	badPadding := append([]byte{}, valid...)
+	badPadding[len(badPadding)-1] ^= 0xff
+	tests := []struct {
+		name       string
+		ciphertext []byte
+		key        []byte
+	}{
+		{"invalid key size", valid, []byte("short")},
+		{"empty", nil, key},
+		{"partial block", valid[:10], key},
+		{"corrupted padding", badPadding, key},
+	}
+	for _, tt := range tests {
+		if got, err := DecryptAESECB(tt.ciphertext, tt.key); err == nil {
+			t.Errorf("%s: DecryptAESECB() = %q, want an error", tt.name, got)
+		}
+	}
+	if _, err := EncryptAESECB([]byte("x"), []byte("short")); err == nil {
+		t.Error("EncryptAESECB accepted an invalid key size")
+	}
+}
@@ -0,0 +1,63 @@
+package wechat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 将以元为单位的金额转换为分, 最多两位小数, 如"12.34"转换为1234
+func YuanToFen(yuan string) (int64, error) {
+	yuan = strings.TrimSpace(yuan)
+	intPart, fracPart := yuan, ""
+	if i := strings.IndexByte(yuan, '.'); i >= 0 {
+		intPart, fracPart = yuan[:i], yuan[i+1:]
+	}
+	if len(intPart) == 0 || len(fracPart) > 2 || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("wechat: invalid yuan amount %q", yuan)
+	}
+	// 小数部分补足两位
+	fracPart += strings.Repeat("0", 2-len(fracPart))
+	fen, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("wechat: invalid yuan amount %q", yuan)
+	}
+	return fen, nil
+}
+
+// 将以分为单位的金额转换为元, 如1234转换为"12.34"
+func FenToYuan(fen int64) string {
+	sign := ""
+	if fen < 0 {
+		sign = "-"
+	}
+	s := strconv.FormatInt(fen, 10)
+	s = strings.TrimPrefix(s, "-")
+	// 不足三位时补零, 保证至少有一位整数
+	if len(s) < 3 {
+		s = strings.Repeat("0", 3-len(s)) + s
+	}
+	return sign + s[:len(s)-2] + "." + s[len(s)-2:]
+}
+
+// 判断字符串是否只包含数字
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// 校验total_fee为正整数(单位为分)
+func validateTotalFee(params Map) error {
+	fee := params.GetString("total_fee")
+	if !isDigits(fee) {
+		return fmt.Errorf("wechat: invalid total_fee %q, must be a positive integer in fen", fee)
+	}
+	if n, err := strconv.ParseInt(fee, 10, 64); err != nil || n <= 0 {
+		return fmt.Errorf("wechat: invalid total_fee %q, must be a positive integer in fen", fee)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package wechat
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestYuanToFen(t *testing.T) {
+	tests := []struct {
+		yuan string
+		want int64
+	}{
+		{"0.01", 1},
+		{"12.34", 1234},
+		{"12.3", 1230},
+		{"12", 1200},
+		{" 0 ", 0},
+		{"99999999.99", 9999999999},
+	}
+	for _, tt := range tests {
+		if got, err := YuanToFen(tt.yuan); err != nil || got != tt.want {
+			t.Errorf("YuanToFen(%q) = %d, %v, want %d", tt.yuan, got, err, tt.want)
+		}
+	}
+	for _, yuan := range []string{"", ".5", "1.234", "-1", "abc", "1e3", "1,00", "99999999999999999999"} {
+		if got, err := YuanToFen(yuan); err == nil {
+			t.Errorf("YuanToFen(%q) = %d, want error", yuan, got)
+		}
+	}
+}
+
+func TestFenToYuan(t *testing.T) {
+	tests := []struct {
+		fen  int64
+		want string
+	}{
+		{0, "0.00"},
+		{1, "0.01"},
+		{1234, "12.34"},
+		{-5, "-0.05"},
+		{9999999999, "99999999.99"},
+	}
+	for _, tt := range tests {
+		if got := FenToYuan(tt.fen); got != tt.want {
+			t.Errorf("FenToYuan(%d) = %q, want %q", tt.fen, got, tt.want)
+		}
+		if tt.fen >= 0 {
+			if fen, err := YuanToFen(tt.want); err != nil || fen != tt.fen {
+				t.Errorf("round trip of %d = %d, %v", tt.fen, fen, err)
+			}
+		}
+	}
+}
+
+func TestValidateTotalFee(t *testing.T) {
+	for _, fee := range []string{"1", "88", "9999999999"} {
+		if err := validateTotalFee(Map{"total_fee": fee}); err != nil {
+			t.Errorf("validateTotalFee(%q) = %v", fee, err)
+		}
+	}
+	for _, fee := range []string{"", "0", "-1", "1.5", "0.01", "abc", "99999999999999999999"} {
+		if err := validateTotalFee(Map{"total_fee": fee}); err == nil {
+			t.Errorf("validateTotalFee(%q) = nil, want error", fee)
+		}
+	}
+}
+
+func TestUnifiedOrderRejectsTotalFee(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("order with an invalid total_fee was sent")
+	})
+	if _, err := c.UnifiedOrder(orderParams().SetString("total_fee", "0.01")); err == nil {
+		t.Error("want error for fractional total_fee")
+	}
+}
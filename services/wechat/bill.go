@@ -0,0 +1,203 @@
+package wechat
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// 下载对账单, 成功时返回原始的CSV数据
+func (c *Client) DownloadBill(params Map) ([]byte, error) {
+	if err := requireParams(params, "bill_date"); err != nil {
+		return nil, err
+	}
+	// 指定url
+	url := c.apiURL(DownloadBillUrl, SandboxDownloadBillUrl)
+	params, err := c.fillRequestData(params)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.doPost(context.Background(), c.client(), url, params)
+	if err != nil {
+		return nil, err
+	}
+	// 失败时微信返回xml格式的错误信息
+	if isXMLBody(data) {
+		return nil, NewWxError(XML(data).ToMap())
+	}
+	// tar_type=GZIP时返回gzip压缩的数据
+	return gunzipIfNeeded(data)
+}
+
+// 检查错误响应时预读的字节数
+const billPeekLen = 512
+
+// 以流的形式下载对账单, 适用于数据量较大的对账单, 调用方需要关闭返回的io.ReadCloser
+// tar_type=GZIP时返回解压后的数据流, 失败时微信返回的xml错误信息在读取数据流之前即被识别
+func (c *Client) DownloadBillStream(ctx context.Context, params Map) (io.ReadCloser, error) {
+	if err := requireParams(params, "bill_date"); err != nil {
+		return nil, err
+	}
+	url := c.apiURL(DownloadBillUrl, SandboxDownloadBillUrl)
+	params, err := c.fillRequestData(params)
+	if err != nil {
+		return nil, err
+	}
+	body := params.ToXML()
+	start := time.Now()
+	stream, head, err := c.openBillStream(ctx, url, body)
+	c.record(url, body, head, time.Since(start), err)
+	return stream, err
+}
+
+// 打开对账单数据流, 失败时返回预读的响应内容用于日志记录
+func (c *Client) openBillStream(ctx context.Context, url string, body XML) (io.ReadCloser, []byte, error) {
+	response, err := c.openFailover(ctx, c.streamClient(), url, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := bufio.NewReaderSize(response.Body, billPeekLen)
+	head, err := reader.Peek(billPeekLen)
+	if err != nil && err != io.EOF {
+		response.Body.Close()
+		return nil, head, err
+	}
+	// 失败时微信返回xml格式的错误信息
+	if isXMLBody(head) {
+		defer response.Body.Close()
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, head, err
+		}
+		return nil, data, NewWxError(XML(data).ToMap())
+	}
+	if !bytes.HasPrefix(head, gzipMagic) {
+		return &billStream{Reader: reader, body: response.Body}, nil, nil
+	}
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		response.Body.Close()
+		return nil, nil, err
+	}
+	return &billStream{Reader: gz, gz: gz, body: response.Body}, nil, nil
+}
+
+// 对账单数据流, 关闭时同时关闭gzip解压器和响应体
+type billStream struct {
+	io.Reader
+	gz   *gzip.Reader
+	body io.Closer
+}
+
+func (s *billStream) Close() error {
+	var err error
+	if s.gz != nil {
+		err = s.gz.Close()
+	}
+	if bodyErr := s.body.Close(); err == nil {
+		err = bodyErr
+	}
+	return err
+}
+
+// gzip数据的魔数
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// 数据为gzip格式时解压
+func gunzipIfNeeded(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// 下载资金账单(需要商户证书), 成功时返回原始的CSV数据
+// 该接口只支持HMAC-SHA256签名, 与客户端的签名类型无关
+func (c *Client) DownloadFundFlow(params Map) ([]byte, error) {
+	if err := requireParams(params, "bill_date", "account_type"); err != nil {
+		return nil, err
+	}
+	h, err := c.certHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	url := c.apiURL(DownloadFundFlowUrl, DownloadFundFlowUrl)
+	params, err = c.fillRequestDataWith(params, HMACSHA256)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.doPost(context.Background(), h, url, params)
+	if err != nil {
+		return nil, err
+	}
+	// 失败时微信返回xml格式的错误信息
+	if isXMLBody(data) {
+		return nil, NewWxError(XML(data).ToMap())
+	}
+	return gunzipIfNeeded(data)
+}
+
+// 对账单汇总数据的表头以此开头
+const billSummaryPrefix = "总交易单数"
+
+// 解析对账单, 返回表头、交易明细和汇总数据
+func ParseBill(data []byte) (header []string, rows []Map, summary Map, err error) {
+	// 去除可能存在的UTF-8 BOM
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, nil, errors.New("wechat: empty bill")
+	}
+
+	header = trimBillFields(records[0])
+	for i := 1; i < len(records); i++ {
+		record := trimBillFields(records[i])
+		// 汇总数据为表头加一行数据
+		if len(record) > 0 && record[0] == billSummaryPrefix {
+			summary = make(Map)
+			if i+1 < len(records) {
+				summary = zipBillRecord(record, trimBillFields(records[i+1]))
+			}
+			break
+		}
+		rows = append(rows, zipBillRecord(header, record))
+	}
+	return header, rows, summary, nil
+}
+
+// 去除字段前后的空白以及微信为防止Excel格式化而添加的反引号
+func trimBillFields(record []string) []string {
+	fields := make([]string, len(record))
+	for i, field := range record {
+		fields[i] = strings.TrimPrefix(strings.TrimSpace(field), "`")
+	}
+	return fields
+}
+
+// 将表头与数据组合为Map
+func zipBillRecord(header, record []string) Map {
+	m := make(Map, len(header))
+	for i, k := range header {
+		if i < len(record) {
+			m.SetString(k, record[i])
+		}
+	}
+	return m
+}
@@ -0,0 +1,342 @@
+package wechat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBill = "\xef\xbb\xbf交易时间,公众账号ID,商户号,商户订单号,总金额\r\n" +
+	"`2014-11-10 16:33:45,`wx2421b1c4370ec43b,`10000100,`1415757673,`0.01\r\n" +
+	"`2014-11-10 16:46:14,`wx2421b1c4370ec43b,`10000100,`1415757674,`0.02\r\n" +
+	"总交易单数,应结订单总金额\r\n" +
+	"`2,`0.03\r\n"
+
+func TestDownloadBill(t *testing.T) {
+	var req Map
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		req = readRequest(t, r)
+		w.Write([]byte(testBill))
+	})
+	data, err := c.DownloadBill(Map{"bill_date": "20141110", "bill_type": "ALL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testBill {
+		t.Errorf("data = %q, want %q", data, testBill)
+	}
+	if req.GetString("bill_date") != "20141110" {
+		t.Errorf("bill_date = %q", req.GetString("bill_date"))
+	}
+}
+
+func TestDownloadBillError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Map{"return_code": FAIL, "return_msg": "No Bill Exist", "error_code": "20002"}.ToXML()))
+	})
+	_, err := c.DownloadBill(Map{"bill_date": "20141110"})
+	var wxErr *WxError
+	if !errors.As(err, &wxErr) || wxErr.ReturnMsg != "No Bill Exist" {
+		t.Errorf("err = %v, want *WxError", err)
+	}
+}
+
+func TestDownloadBillRequest(t *testing.T) {
+	var (
+		req   Map
+		calls int
+	)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/pay/downloadbill" {
+			http.NotFound(w, r)
+			return
+		}
+		req = readRequest(t, r)
+		w.Write([]byte(testBill))
+	})
+	if _, err := c.DownloadBill(Map{"bill_date": "20141110", "bill_type": "ALL"}); err != nil {
+		t.Fatal(err)
+	}
+	if req.GetString("appid") != testAppID || req.GetString("bill_type") != "ALL" || !c.VerifySign(req) {
+		t.Errorf("unexpected request: %v", req)
+	}
+	if _, err := c.DownloadBill(Map{"bill_type": "ALL"}); err == nil || calls != 1 {
+		t.Errorf("DownloadBill without bill_date = %v after %d requests", err, calls)
+	}
+}
+
+func TestDownloadBillSandbox(t *testing.T) {
+	var path string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(testBill))
+	})
+	c.account = NewAccount(testAppID, testMchID, testAPIKey, true)
+	if _, err := c.DownloadBill(Map{"bill_date": "20141110"}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/sandboxnew/pay/downloadbill" {
+		t.Errorf("path = %q, want /sandboxnew/pay/downloadbill", path)
+	}
+}
+
+func TestDownloadBillGzip(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(testBill))
+		gz.Close()
+	})
+	data, err := c.DownloadBill(Map{"bill_date": "20141110", "tar_type": "GZIP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testBill {
+		t.Errorf("data = %q, want %q", data, testBill)
+	}
+}
+
+func TestGunzipIfNeeded(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(testBill))
+	gz.Close()
+	tests := []struct {
+		name    string
+		in      []byte
+		want    string
+		wantErr bool
+	}{
+		{"gzip", buf.Bytes(), testBill, false},
+		{"plain", []byte(testBill), testBill, false},
+		{"truncated gzip", buf.Bytes()[:buf.Len()/2], "", true},
+		{"gzip magic only", gzipMagic, "", true},
+	}
+	for _, tt := range tests {
+		got, err := gunzipIfNeeded(tt.in)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && string(got) != tt.want) {
+			t.Errorf("%s: gunzipIfNeeded() = %q, %v", tt.name, got, err)
+		}
+	}
+}
+
+func TestParseBill(t *testing.T) {
+	header, rows, summary, err := ParseBill([]byte(testBill))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(header) != 5 || header[0] != "交易时间" {
+		t.Errorf("header = %q", header)
+	}
+	if len(rows) != 2 || rows[1].GetString("商户订单号") != "1415757674" {
+		t.Errorf("rows = %v", rows)
+	}
+	if summary.GetString("总交易单数") != "2" || summary.GetString("应结订单总金额") != "0.03" {
+		t.Errorf("summary = %v", summary)
+	}
+}
+
+func TestParseBillEdgeCases(t *testing.T) {
+	if _, _, _, err := ParseBill(nil); err == nil {
+		t.Error("want error for an empty bill")
+	}
+	if _, _, _, err := ParseBill([]byte("\xef\xbb\xbf")); err == nil {
+		t.Error("want error for a bill with only a BOM")
+	}
+	// 没有汇总数据, 短行只填充已有的字段
+	header, rows, summary, err := ParseBill([]byte("交易时间,商户订单号,总金额\n`2014-11-10 16:33:45,`1415757673 \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(header) != 3 || len(rows) != 1 || summary != nil {
+		t.Fatalf("ParseBill() = %q, %v, %v", header, rows, summary)
+	}
+	if rows[0].GetString("商户订单号") != "1415757673" || rows[0].ContainsKey("总金额") {
+		t.Errorf("row = %v", rows[0])
+	}
+	// 汇总表头之后没有数据
+	if _, rows, summary, err := ParseBill([]byte("商户订单号\n`1415757673\n总交易单数,应结订单总金额\n")); err != nil || len(rows) != 1 || summary == nil || len(summary) != 0 {
+		t.Errorf("ParseBill() = %v, %v, %v", rows, summary, err)
+	}
+}
+
+func TestDownloadBillStream(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(testBill))
+		gz.Close()
+	})
+	rc, err := c.DownloadBillStream(context.Background(), Map{"bill_date": "20141110", "tar_type": "GZIP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testBill {
+		t.Errorf("data = %q, want %q", data, testBill)
+	}
+}
+
+func TestDownloadBillStreamError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(xmlDeclaration + Map{"return_code": FAIL, "return_msg": "No Bill Exist"}.ToXML()))
+	})
+	_, err := c.DownloadBillStream(context.Background(), Map{"bill_date": "20141110"})
+	var wxErr *WxError
+	if !errors.As(err, &wxErr) || wxErr.ReturnMsg != "No Bill Exist" {
+		t.Errorf("err = %v, want *WxError", err)
+	}
+}
+
+// 数据流的读取时间超过客户端的超时时间时不应被截断
+func TestDownloadBillStreamSlowBody(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			w.Write([]byte(strings.Repeat("`1415757673,`0.01\r\n", 100)))
+			w.(http.Flusher).Flush()
+			time.Sleep(150 * time.Millisecond)
+		}
+	})
+	c.SetConnectTimeout(100)
+	c.SetReadTimeout(100)
+	rc, err := c.DownloadBillStream(context.Background(), Map{"bill_date": "20141110"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := bytes.Repeat([]byte("`1415757673,`0.01\r\n"), 300); !bytes.Equal(data, want) {
+		t.Errorf("read %d bytes, want %d", len(data), len(want))
+	}
+}
+
+func TestDownloadBillStreamHooks(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(xmlDeclaration + Map{"return_code": FAIL, "return_msg": "No Bill Exist"}.ToXML()))
+	})
+	logger := new(testLogger)
+	c.SetLogger(logger)
+	var endpoint string
+	c.SetMetrics(func(e string, d time.Duration, err error) {
+		endpoint = e
+	})
+	if _, err := c.DownloadBillStream(context.Background(), Map{"bill_date": "20141110"}); err == nil {
+		t.Fatal("want an error")
+	}
+	if endpoint != "/pay/downloadbill" {
+		t.Errorf("metrics endpoint = %q, want /pay/downloadbill", endpoint)
+	}
+	if !strings.Contains(string(logger.req), "20141110") ||
+		!strings.Contains(string(logger.resp), "No Bill Exist") || logger.err == nil {
+		t.Errorf("logger = %+v", logger)
+	}
+	if c.LastLatency() <= 0 {
+		t.Errorf("LastLatency() = %v", c.LastLatency())
+	}
+}
+
+func TestDownloadBillStreamFallbackHosts(t *testing.T) {
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testBill))
+	}))
+	defer backup.Close()
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	c.SetBaseURL(closedServerURL())
+	c.SetFallbackHosts(backup.URL)
+	rc, err := c.DownloadBillStream(context.Background(), Map{"bill_date": "20141110"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if data, _ := ioutil.ReadAll(rc); string(data) != testBill {
+		t.Errorf("data = %q, want %q", data, testBill)
+	}
+}
+
+// 预读响应失败时返回错误, 而不是返回不完整的数据流
+func TestDownloadBillStreamPeekError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.Write([]byte(testBill))
+	})
+	if rc, err := c.DownloadBillStream(context.Background(), Map{"bill_date": "20141110"}); err == nil {
+		rc.Close()
+		t.Error("want an error for a truncated body")
+	}
+}
+
+func TestBillStreamCloseGzip(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(testBill))
+		gz.Close()
+	})
+	rc, err := c.DownloadBillStream(context.Background(), Map{"bill_date": "20141110", "tar_type": "GZIP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, ok := rc.(*billStream)
+	if !ok || stream.gz == nil {
+		t.Fatalf("stream = %#v, want a gzip billStream", rc)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(rc); err == nil {
+		t.Error("read after Close succeeded")
+	}
+}
+
+func TestDownloadFundFlow(t *testing.T) {
+	var req Map
+	c := newTestCertClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pay/downloadfundflow" {
+			http.NotFound(w, r)
+			return
+		}
+		req = readRequest(t, r)
+		w.Write([]byte(testBill))
+	})
+	data, err := c.DownloadFundFlow(Map{"bill_date": "20141110", "account_type": "Basic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testBill {
+		t.Errorf("data = %q", data)
+	}
+	// 资金账单只支持HMAC-SHA256签名
+	if req.GetString("sign_type") != HMACSHA256 || !c.VerifySign(req) {
+		t.Errorf("request not signed with HMAC-SHA256: %v", req)
+	}
+	if _, err := c.DownloadFundFlow(Map{"bill_date": "20141110"}); err == nil {
+		t.Error("want error without account_type")
+	}
+}
+
+func TestDownloadFundFlowError(t *testing.T) {
+	c := newTestCertClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Map{"return_code": FAIL, "return_msg": "No Bill Exist"}.ToXML()))
+	})
+	_, err := c.DownloadFundFlow(Map{"bill_date": "20141110", "account_type": "Basic"})
+	var wxErr *WxError
+	if !errors.As(err, &wxErr) || wxErr.ReturnMsg != "No Bill Exist" {
+		t.Errorf("err = %v, want *WxError", err)
+	}
+	c = NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	if _, err := c.DownloadFundFlow(Map{"bill_date": "20141110", "account_type": "Basic"}); err != ErrMissingCert {
+		t.Errorf("err = %v, want ErrMissingCert", err)
+	}
+}
@@ -0,0 +1,103 @@
+package wechat
+
+import "time"
+
+// 统一下单参数构造器
+type UnifiedOrderBuilder struct {
+	params Map
+}
+
+// 创建统一下单参数构造器
+func NewUnifiedOrderBuilder() *UnifiedOrderBuilder {
+	return &UnifiedOrderBuilder{params: make(Map)}
+}
+
+// 商品描述
+func (b *UnifiedOrderBuilder) Body(body string) *UnifiedOrderBuilder {
+	b.params.SetString("body", body)
+	return b
+}
+
+// 商户订单号
+func (b *UnifiedOrderBuilder) OutTradeNo(outTradeNo string) *UnifiedOrderBuilder {
+	b.params.SetString("out_trade_no", outTradeNo)
+	return b
+}
+
+// 订单总金额, 单位为分
+func (b *UnifiedOrderBuilder) TotalFee(fee int64) *UnifiedOrderBuilder {
+	b.params.SetInt64("total_fee", fee)
+	return b
+}
+
+// 支付结果通知地址
+func (b *UnifiedOrderBuilder) NotifyURL(url string) *UnifiedOrderBuilder {
+	b.params.SetString("notify_url", url)
+	return b
+}
+
+// 交易类型
+func (b *UnifiedOrderBuilder) TradeType(tradeType string) *UnifiedOrderBuilder {
+	b.params.SetString("trade_type", tradeType)
+	return b
+}
+
+// 用户标识, JSAPI支付必填
+func (b *UnifiedOrderBuilder) OpenID(openID string) *UnifiedOrderBuilder {
+	b.params.SetString("openid", openID)
+	return b
+}
+
+// 商品ID, Native支付必填
+func (b *UnifiedOrderBuilder) ProductID(productID string) *UnifiedOrderBuilder {
+	b.params.SetString("product_id", productID)
+	return b
+}
+
+// 终端ip
+func (b *UnifiedOrderBuilder) SpbillCreateIP(ip string) *UnifiedOrderBuilder {
+	b.params.SetString("spbill_create_ip", ip)
+	return b
+}
+
+// 订单有效期
+func (b *UnifiedOrderBuilder) TimeExpire(d time.Duration) *UnifiedOrderBuilder {
+	b.params.SetTimeExpire(d)
+	return b
+}
+
+// 禁止使用信用卡支付
+func (b *UnifiedOrderBuilder) DisableCreditCard() *UnifiedOrderBuilder {
+	b.params.SetString("limit_pay", "no_credit")
+	return b
+}
+
+// 开启电子发票入口
+func (b *UnifiedOrderBuilder) EnableReceipt() *UnifiedOrderBuilder {
+	b.params.SetString("receipt", "Y")
+	return b
+}
+
+// 校验并生成统一下单参数
+func (b *UnifiedOrderBuilder) Build() (Map, error) {
+	err := requireParams(b.params, "body", "out_trade_no", "total_fee", "notify_url", "trade_type", "spbill_create_ip")
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTotalFee(b.params); err != nil {
+		return nil, err
+	}
+	if err := validateNotifyURL(b.params); err != nil {
+		return nil, err
+	}
+	switch b.params.GetString("trade_type") {
+	case TradeTypeJSAPI:
+		err = requireParams(b.params, "openid")
+	case TradeTypeNative:
+		err = requireParams(b.params, "product_id")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b.params.Clone(), nil
+}
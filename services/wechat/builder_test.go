@@ -0,0 +1,75 @@
+package wechat
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestBuilder() *UnifiedOrderBuilder {
+	return NewUnifiedOrderBuilder().
+		Body("腾讯充值中心-QQ会员充值").
+		OutTradeNo("20150806125346").
+		TotalFee(88).
+		NotifyURL("https://www.weixin.qq.com/wxpay/pay.php").
+		SpbillCreateIP("123.12.12.123")
+}
+
+func TestUnifiedOrderBuilder(t *testing.T) {
+	b := newTestBuilder().TradeType(TradeTypeJSAPI).OpenID("oUpF8uMuAJO_M2pxb1Q9zNjWeS6o").TimeExpire(time.Hour)
+	params, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Map{"body": "腾讯充值中心-QQ会员充值", "out_trade_no": "20150806125346", "total_fee": "88",
+		"notify_url": "https://www.weixin.qq.com/wxpay/pay.php", "spbill_create_ip": "123.12.12.123",
+		"trade_type": TradeTypeJSAPI, "openid": "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"}
+	for k, v := range want {
+		if params.GetString(k) != v {
+			t.Errorf("%s = %q, want %q", k, params.GetString(k), v)
+		}
+	}
+	if params.GetString("time_start") == "" || params.GetString("time_expire") == "" {
+		t.Errorf("time range missing: %v", params)
+	}
+	// Build返回副本
+	params.SetString("body", "changed")
+	if again, _ := b.Build(); again.GetString("body") != "腾讯充值中心-QQ会员充值" {
+		t.Error("Build() returned the builder's own map")
+	}
+}
+
+func TestUnifiedOrderBuilderValidates(t *testing.T) {
+	tests := []struct {
+		name string
+		b    *UnifiedOrderBuilder
+	}{
+		{"missing body", NewUnifiedOrderBuilder().OutTradeNo("1").TotalFee(1).NotifyURL("https://example.com/n").TradeType(TradeTypeApp).SpbillCreateIP("1.1.1.1")},
+		{"zero total_fee", newTestBuilder().TotalFee(0).TradeType(TradeTypeApp)},
+		{"relative notify_url", newTestBuilder().NotifyURL("/wxpay/notify").TradeType(TradeTypeApp)},
+		{"jsapi without openid", newTestBuilder().TradeType(TradeTypeJSAPI)},
+		{"native without product_id", newTestBuilder().TradeType(TradeTypeNative)},
+	}
+	for _, tt := range tests {
+		if _, err := tt.b.Build(); err == nil {
+			t.Errorf("%s: Build() = nil error", tt.name)
+		}
+	}
+	if _, err := newTestBuilder().TradeType(TradeTypeNative).ProductID("12235413214070356458058").Build(); err != nil {
+		t.Errorf("native order: %v", err)
+	}
+}
+
+func TestUnifiedOrderBuilderLimitPayAndReceipt(t *testing.T) {
+	params, err := newTestBuilder().TradeType(TradeTypeApp).DisableCreditCard().EnableReceipt().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.GetString("limit_pay") != "no_credit" || params.GetString("receipt") != "Y" {
+		t.Errorf("limit_pay = %q, receipt = %q", params.GetString("limit_pay"), params.GetString("receipt"))
+	}
+	// 默认不设置
+	params, _ = newTestBuilder().TradeType(TradeTypeApp).Build()
+	if params.ContainsKey("limit_pay") || params.ContainsKey("receipt") {
+		t.Errorf("unexpected defaults: %v", params)
+	}
+}
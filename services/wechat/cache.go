@@ -0,0 +1,97 @@
+package wechat
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 查询订单结果的缓存, 相同out_trade_no的并发查询只向微信发送一次请求
+type queryCache struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	calls map[string]*queryCall
+}
+
+// 一次查询订单请求, done关闭后res和err可读
+type queryCall struct {
+	done    chan struct{}
+	res     Map
+	err     error
+	expires time.Time
+}
+
+// 开启查询订单的缓存, ttl时间内相同out_trade_no的查询共享同一次请求的结果
+// 适用于多个请求并发轮询同一订单的场景, ttl不大于0时关闭缓存
+func (c *Client) EnableQueryCache(ttl time.Duration) {
+	if ttl <= 0 {
+		c.queryCache = nil
+		return
+	}
+	c.queryCache = &queryCache{ttl: ttl, calls: make(map[string]*queryCall)}
+}
+
+// 从缓存中读取key对应的结果, 没有可用的结果时执行fn
+// 请求失败的结果不会缓存, 只与正在等待的查询共享; 等待中的调用方在ctx结束时返回ctx.Err()
+func (q *queryCache) do(ctx context.Context, key string, fn func() (Map, error)) (Map, error) {
+	q.mu.Lock()
+	call, ok := q.calls[key]
+	if ok && isDone(call.done) && time.Now().After(call.expires) {
+		ok = false
+	}
+	if !ok {
+		q.evictExpired()
+		call = &queryCall{done: make(chan struct{})}
+		q.calls[key] = call
+		go q.run(key, call, fn)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// 执行共享的请求, 完成后通知所有等待的调用方
+func (q *queryCache) run(key string, call *queryCall, fn func() (Map, error)) {
+	res, err := fn()
+	q.mu.Lock()
+	call.res, call.err = res, err
+	call.expires = time.Now().Add(q.ttl)
+	if err != nil && q.calls[key] == call {
+		delete(q.calls, key)
+	}
+	q.mu.Unlock()
+	close(call.done)
+}
+
+// 清除已过期的结果, 调用方需持有锁
+func (q *queryCache) evictExpired() {
+	now := time.Now()
+	for key, call := range q.calls {
+		if isDone(call.done) && now.After(call.expires) {
+			delete(q.calls, key)
+		}
+	}
+}
+
+// 返回结果的副本, 避免调用方修改缓存中的结果
+func (call *queryCall) result() (Map, error) {
+	if call.res == nil {
+		return nil, call.err
+	}
+	return call.res.Clone(), call.err
+}
+
+// 判断channel是否已关闭
+func isDone(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,105 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 使用go test -race运行
+func TestQueryCacheSharesConcurrentQueries(t *testing.T) {
+	var n int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		time.Sleep(50 * time.Millisecond)
+		writeSigned(w, successResponse().SetString("trade_state", TradeStateNotPay), MD5)
+	})
+	c.EnableQueryCache(time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if res.GetString("trade_state") != TradeStateNotPay {
+				t.Errorf("trade_state = %q", res.GetString("trade_state"))
+			}
+			// 修改返回的结果不影响其他调用方
+			res.SetString("trade_state", TradeStateSuccess)
+		}()
+	}
+	wg.Wait()
+	if _, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&n); got != 1 {
+		t.Errorf("upstream requests = %d, want 1", got)
+	}
+}
+
+func TestQueryCacheExpires(t *testing.T) {
+	var n int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		writeSigned(w, successResponse().SetString("trade_state", TradeStateNotPay), MD5)
+	})
+	c.EnableQueryCache(20 * time.Millisecond)
+	c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"})
+	time.Sleep(30 * time.Millisecond)
+	c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"})
+	if got := atomic.LoadInt32(&n); got != 2 {
+		t.Errorf("upstream requests = %d, want 2", got)
+	}
+}
+
+// 发起共享请求的调用方取消后, 其他调用方仍然得到结果
+func TestQueryCacheLeaderCancel(t *testing.T) {
+	received := make(chan struct{})
+	release := make(chan struct{})
+	var n int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			close(received)
+		}
+		<-release
+		writeSigned(w, successResponse().SetString("trade_state", TradeStateSuccess), MD5)
+	})
+	c.EnableQueryCache(time.Second)
+	params := Map{"out_trade_no": "1217752501201407033233368018"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.OrderQueryContext(ctx, params)
+		leaderErr <- err
+	}()
+	<-received
+
+	waiter := make(chan Map, 1)
+	go func() {
+		res, err := c.OrderQuery(params)
+		if err != nil {
+			t.Error(err)
+		}
+		waiter <- res
+	}()
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("leader err = %v, want context.Canceled", err)
+	}
+	close(release)
+	if res := <-waiter; res.GetString("trade_state") != TradeStateSuccess {
+		t.Errorf("waiter trade_state = %q, want SUCCESS", res.GetString("trade_state"))
+	}
+	if got := atomic.LoadInt32(&n); got != 1 {
+		t.Errorf("upstream requests = %d, want 1", got)
+	}
+}
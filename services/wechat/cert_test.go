@@ -0,0 +1,108 @@
+package wechat
+
+import (
+	"crypto/rsa"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestCertificateMissing(t *testing.T) {
+	a := NewAccount(testAppID, testMchID, testAPIKey, false)
+	if _, err := a.certificate(); err != ErrMissingCert {
+		t.Errorf("err = %v, want ErrMissingCert", err)
+	}
+}
+
+func TestCertificateInvalidP12(t *testing.T) {
+	a := NewAccountWithCert(testAppID, testMchID, testAPIKey, []byte("not a p12 file"), false)
+	if _, err := a.certificate(); err == nil || err == ErrMissingCert {
+		t.Errorf("err = %v, want a p12 decode error", err)
+	}
+	c := NewClient(a)
+	if _, err := c.certHTTPClient(); err == nil {
+		t.Error("cert client created from invalid p12 data")
+	}
+}
+
+func TestNewAccountWithCert(t *testing.T) {
+	a := NewAccountWithCert(testAppID, testMchID, testAPIKey, []byte("p12 data"), true)
+	if string(a.certData) != "p12 data" || a.appID != testAppID || a.mchID != testMchID || !a.isSandbox {
+		t.Errorf("unexpected account: %#v", a)
+	}
+	a = NewAccountWithCert(testAppID, testMchID, testAPIKey, nil, false)
+	if _, err := a.certificate(); err != ErrMissingCert {
+		t.Errorf("err = %v, want ErrMissingCert", err)
+	}
+}
+
+func TestCertificatePEM(t *testing.T) {
+	certPEM, keyPEM := newTestCertPEM(t)
+	a := NewAccountWithPEM(testAppID, testMchID, testAPIKey, certPEM, keyPEM, false)
+	// PEM证书优先于p12证书
+	a.certData = []byte("not a p12 file")
+	cert, err := a.certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.Certificate) != 1 || cert.PrivateKey == nil {
+		t.Errorf("unexpected certificate: %+v", cert)
+	}
+	_, otherKey := newTestCertPEM(t)
+	if _, err := NewAccountWithPEM(testAppID, testMchID, testAPIKey, certPEM, otherKey, false).certificate(); err == nil {
+		t.Error("want error for mismatched key")
+	}
+}
+
+// testdata/apiclient_cert.p12为自签名的测试证书, 密码为testMchID
+func readTestP12(t *testing.T) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile("testdata/apiclient_cert.p12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCertificateP12(t *testing.T) {
+	a := NewAccountWithCert(testAppID, testMchID, testAPIKey, readTestP12(t), false)
+	cert, err := a.certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.Certificate) != 1 || cert.Leaf == nil || cert.Leaf.Subject.CommonName != testMchID {
+		t.Errorf("unexpected certificate: %+v", cert.Leaf)
+	}
+	if _, ok := cert.PrivateKey.(*rsa.PrivateKey); !ok {
+		t.Errorf("PrivateKey = %T, want *rsa.PrivateKey", cert.PrivateKey)
+	}
+	h, err := NewClient(a).certHTTPClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr, ok := h.Transport.(*http.Transport); !ok || len(tr.TLSClientConfig.Certificates) != 1 {
+		t.Errorf("cert client does not carry the certificate: %#v", h.Transport)
+	}
+	// 密码为商户号, 其他商户号无法解析
+	if _, err := NewAccountWithCert(testAppID, "10000101", testAPIKey, readTestP12(t), false).certificate(); err == nil {
+		t.Error("p12 decoded with the wrong password")
+	}
+}
+
+func TestCertHTTPClientPresentsP12(t *testing.T) {
+	var subject string
+	a := NewAccountWithCert(testAppID, testMchID, testAPIKey, readTestP12(t), false)
+	c := newTestTLSClient(t, a, func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			subject = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		writeSigned(w, successResponse().SetString("refund_id", "50000408942018111907145868882"), MD5)
+	})
+	if _, err := c.Refund(Map{"out_trade_no": "1217752501201407033233368018", "out_refund_no": "1217752501201407033233368018",
+		"total_fee": "100", "refund_fee": "100"}); err != nil {
+		t.Fatal(err)
+	}
+	if subject != testMchID {
+		t.Errorf("client certificate subject = %q, want %q", subject, testMchID)
+	}
+}
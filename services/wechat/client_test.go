@@ -0,0 +1,210 @@
+package wechat
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadTimeout(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-done:
+		}
+	})
+	if err := c.SetReadTimeout(50); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"})
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("err = %v, want a timeout error", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("request took %v, want it to stop at the read timeout", d)
+	}
+}
+
+func TestInvalidTimeout(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	if err := c.SetConnectTimeout(0); err != ErrInvalidTimeout {
+		t.Errorf("SetConnectTimeout(0) = %v, want ErrInvalidTimeout", err)
+	}
+	if err := c.SetReadTimeout(-1); err != ErrInvalidTimeout {
+		t.Errorf("SetReadTimeout(-1) = %v, want ErrInvalidTimeout", err)
+	}
+}
+
+func TestSetTimeouts(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	old := c.client()
+	if err := c.SetConnectTimeout(1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetReadTimeout(2000); err != nil {
+		t.Fatal(err)
+	}
+	h := c.client()
+	if h == old {
+		t.Error("client() not rebuilt after changing the timeouts")
+	}
+	if h.Timeout != 3*time.Second {
+		t.Errorf("Timeout = %v, want connect + read timeout", h.Timeout)
+	}
+	if tr, ok := h.Transport.(*http.Transport); !ok || tr.ResponseHeaderTimeout != 2*time.Second {
+		t.Errorf("Transport = %#v, want ResponseHeaderTimeout 2s", h.Transport)
+	}
+	// 设置失败时保留原有的超时时间
+	c.SetReadTimeout(0)
+	if c.client().Timeout != 3*time.Second {
+		t.Errorf("Timeout = %v after an invalid read timeout", c.client().Timeout)
+	}
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeSigned(w, successResponse(), MD5)
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	c.SetBaseURL(srv.URL)
+	for i := 0; i < 20; i++ {
+		if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("opened %d connections for sequential requests, want 1", n)
+	}
+	if c.client() != c.client() {
+		t.Error("client() builds a new http.Client per call")
+	}
+}
+
+func TestWithSignType(t *testing.T) {
+	_, keyPEM := newTestRSAKey(t)
+	account := NewAccount(testAppID, testMchID, testAPIKey, false)
+	if err := account.SetPrivateKey(keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(account)
+	clone, err := c.WithSignType(HMACSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.signType != MD5 || clone.signType != HMACSHA256 {
+		t.Errorf("signType = %q/%q, want MD5/HMAC-SHA256", c.signType, clone.signType)
+	}
+	if err := clone.SetSignType(RSA); err != nil || c.signType != MD5 {
+		t.Errorf("clone SetSignType(RSA) = %v, original signType = %q", err, c.signType)
+	}
+	if _, err := c.WithSignType("BOGUS"); err != ErrInvalidSignType {
+		t.Errorf("WithSignType(BOGUS) err = %v, want ErrInvalidSignType", err)
+	}
+}
+
+// 使用go test -race运行, 请求进行中复制客户端不应出现数据竞争
+func TestWithSignTypeConcurrent(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeSigned(w, successResponse(), MD5)
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"})
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if _, err := c.WithSignType(HMACSHA256); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
+
+// 使用函数实现的http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestSetHTTPClient(t *testing.T) {
+	var calls int
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	c.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		if r.URL.String() != CloseOrderUrl {
+			t.Errorf("url = %s, want %s", r.URL, CloseOrderUrl)
+		}
+		rec := httptest.NewRecorder()
+		writeSigned(rec, successResponse(), MD5)
+		return rec.Result(), nil
+	})})
+	if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("custom transport called %d times, want 1", calls)
+	}
+}
+
+func TestSetBaseURL(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		writeSigned(w, successResponse(), MD5)
+	}))
+	defer srv.Close()
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	if got := c.apiURL(OrderQueryUrl, SandboxOrderQueryUrl); got != OrderQueryUrl {
+		t.Errorf("default apiURL = %s, want %s", got, OrderQueryUrl)
+	}
+	c.SetBaseURL(srv.URL + "/")
+	if _, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/pay/orderquery" {
+		t.Errorf("path = %q, want /pay/orderquery", path)
+	}
+}
+
+func TestNewClientWithSignType(t *testing.T) {
+	_, keyPEM := newTestRSAKey(t)
+	account := NewAccount(testAppID, testMchID, testAPIKey, false)
+	if err := account.SetPrivateKey(keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	for _, signType := range []string{MD5, HMACSHA256, RSA} {
+		c, err := NewClientWithSignType(account, signType)
+		if err != nil || c.signType != signType {
+			t.Errorf("NewClientWithSignType(%s) = %v, %v", signType, c, err)
+		}
+	}
+	if c, err := NewClientWithSignType(account, "SHA1"); err != ErrInvalidSignType || c != nil {
+		t.Errorf("NewClientWithSignType(SHA1) = %v, %v, want ErrInvalidSignType", c, err)
+	}
+	c := NewClient(account)
+	if err := c.SetSignType("md5"); err != ErrInvalidSignType || c.signType != MD5 {
+		t.Errorf("SetSignType(md5) err = %v, signType = %s", err, c.signType)
+	}
+	if err := c.SetSignType(HMACSHA256); err != nil || c.signType != HMACSHA256 {
+		t.Errorf("SetSignType(HMAC-SHA256) err = %v, signType = %s", err, c.signType)
+	}
+}
@@ -0,0 +1,81 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+// 合单支付订单, 由公共参数和多个子订单组成
+// 子订单序列化为<sub_orders><sub_order>...</sub_order></sub_orders>嵌套结构
+type CombineOrder struct {
+	params    Map   // 合单公共参数
+	subOrders []Map // 子订单参数
+}
+
+// 创建合单支付订单
+func NewCombineOrder(params Map) *CombineOrder {
+	if params == nil {
+		params = make(Map)
+	}
+	return &CombineOrder{params: params}
+}
+
+// 添加子订单, 子订单通常包含mch_id、out_trade_no、total_fee、body等参数
+func (o *CombineOrder) AddSubOrder(sub Map) *CombineOrder {
+	o.subOrders = append(o.subOrders, sub)
+	return o
+}
+
+// 子订单序列化后的xml, 不含外层的sub_orders元素
+func (o *CombineOrder) subOrdersXML() string {
+	var buf bytes.Buffer
+	for _, sub := range o.subOrders {
+		buf.WriteString(`<sub_order>`)
+		buf.WriteString(strings.TrimSuffix(strings.TrimPrefix(string(sub.ToXML()), `<xml>`), `</xml>`))
+		buf.WriteString(`</sub_order>`)
+	}
+	return buf.String()
+}
+
+// 转换为嵌套的xml字符串
+func (o *CombineOrder) ToXML() XML {
+	flat := string(o.params.ToXML())
+	return XML(strings.TrimSuffix(flat, `</xml>`) + `<sub_orders>` + o.subOrdersXML() + `</sub_orders></xml>`)
+}
+
+// 填充account中的参数并签名, 返回可直接发送的xml
+// 签名时sub_orders的值为子订单序列化后的xml; 参数在副本上填充, 不修改o中的参数
+func (c *Client) SignCombineOrder(o *CombineOrder) (XML, error) {
+	params := o.params.Clone()
+	trimValues(params)
+	params.SetString("appid", c.account.appID).
+		SetString("mch_id", c.account.mchID).
+		SetString("nonce_str", c.nonce()).
+		SetString("sign_type", c.signType)
+	signParams := params.Clone().SetString("sub_orders", o.subOrdersXML())
+	sign, err := c.signWith(signParams, c.signType)
+	if err != nil {
+		return "", err
+	}
+	params.SetString("sign", sign)
+	signed := &CombineOrder{params: params, subOrders: o.subOrders}
+	return signed.ToXML(), nil
+}
+
+// 签名并发送合单支付请求, url为开通合单支付后微信提供的下单地址
+// url以DefaultBaseURL开头时, 前缀会替换为SetBaseURL设置的地址
+func (c *Client) CombineOrderPay(ctx context.Context, url string, o *CombineOrder) (Map, error) {
+	body, err := c.SignCombineOrder(o)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(url, DefaultBaseURL) {
+		url = c.apiURL(url, url)
+	}
+	raw, err := c.doPostBody(ctx, c.client(), url, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.parseResponse(raw, c.signType)
+}
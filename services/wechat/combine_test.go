@@ -0,0 +1,81 @@
+package wechat
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 拆分合单xml, 返回公共参数和sub_orders元素的内容
+func splitCombineXML(t *testing.T, x XML) (Map, string) {
+	t.Helper()
+	s := string(x)
+	start, end := strings.Index(s, `<sub_orders>`), strings.Index(s, `</sub_orders>`)
+	if start < 0 || end < start {
+		t.Fatalf("sub_orders not found in %s", s)
+	}
+	subOrders := s[start+len(`<sub_orders>`) : end]
+	return XML(s[:start] + s[end+len(`</sub_orders>`):]).ToMap(), subOrders
+}
+
+func TestSignCombineOrder(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	params := Map{"combine_out_trade_no": " 1217752501201407033233368018 ", "trade_type": "NATIVE"}
+	o := NewCombineOrder(params).
+		AddSubOrder(Map{"mch_id": "1900000109", "out_trade_no": "20150806125346", "total_fee": "1"}).
+		AddSubOrder(Map{"mch_id": "1900000110", "out_trade_no": "20150806125347", "total_fee": "2"})
+	x, err := c.SignCombineOrder(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params) != 2 || params.GetString("combine_out_trade_no") != " 1217752501201407033233368018 " {
+		t.Errorf("caller params modified: %v", params)
+	}
+	if strings.Count(string(x), `<sub_order>`) != 2 {
+		t.Errorf("sub orders not nested: %s", x)
+	}
+
+	m, subOrders := splitCombineXML(t, x)
+	for _, k := range []string{"appid", "mch_id", "nonce_str", "sign_type", "sign"} {
+		if m.GetString(k) == "" {
+			t.Errorf("%s missing", k)
+		}
+	}
+	if m.GetString("combine_out_trade_no") != "1217752501201407033233368018" {
+		t.Errorf("value not trimmed: %q", m.GetString("combine_out_trade_no"))
+	}
+	if !c.VerifySign(m.SetString("sub_orders", subOrders)) {
+		t.Error("sign does not cover sub_orders")
+	}
+	if c.VerifySign(m.SetString("sub_orders", strings.Replace(subOrders, "2", "3", 1))) {
+		t.Error("tampered sub_orders verified")
+	}
+}
+
+func TestCombineOrderPay(t *testing.T) {
+	var body XML
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pay/combinedorder" {
+			http.NotFound(w, r)
+			return
+		}
+		raw, _ := ioutil.ReadAll(r.Body)
+		body = XML(raw)
+		writeSigned(w, successResponse().SetString("prepay_id", "wx201410272009395522657a690389285100"), MD5)
+	})
+	o := NewCombineOrder(Map{"combine_out_trade_no": "1217752501201407033233368018"}).
+		AddSubOrder(Map{"mch_id": "1900000109", "out_trade_no": "20150806125346", "total_fee": "1"})
+	res, err := c.CombineOrderPay(context.Background(), DefaultBaseURL+"/pay/combinedorder", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetString("prepay_id") == "" {
+		t.Errorf("unexpected response: %v", res)
+	}
+	m, subOrders := splitCombineXML(t, body)
+	if !c.VerifySign(m.SetString("sub_orders", subOrders)) {
+		t.Errorf("request sign invalid: %s", body)
+	}
+}
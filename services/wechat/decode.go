@@ -0,0 +1,93 @@
+package wechat
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 将Map中的字段解析到结构体v中, v必须为结构体指针
+// 字段名取自xml标签, 没有标签时使用字段名的小写形式, 标签为"-"的字段会被忽略
+// 支持string、整数(如以分为单位的金额)、浮点数和bool类型的字段
+func (m Map) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("wechat: Decode requires a non-nil struct pointer")
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" { // 跳过未导出的字段
+			continue
+		}
+		name := strings.Split(field.Tag.Get("xml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if !m.ContainsKey(name) {
+			continue
+		}
+		if err := setField(rv.Field(i), m.GetString(name)); err != nil {
+			return fmt.Errorf("wechat: decode field %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// 将字符串转换为字段对应的类型并赋值
+func setField(field reflect.Value, s string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			return nil
+		}
+		i, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			return nil
+		}
+		u, err := strconv.ParseUint(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		if s == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported kind %s", field.Kind())
+	}
+	return nil
+}
+
+// 将xml解析到结构体v中, 参见Map.Decode
+func Unmarshal(x XML, v interface{}) error {
+	return x.ToMap().Decode(v)
+}
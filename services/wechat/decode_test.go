@@ -0,0 +1,63 @@
+package wechat
+
+import (
+	"testing"
+)
+
+type testOrder struct {
+	OutTradeNo  string  `xml:"out_trade_no"`
+	TotalFee    int64   `xml:"total_fee"`
+	CashFee     uint    `xml:"cash_fee,omitempty"`
+	Rate        float64 `xml:"rate"`
+	IsSubscribe bool    `xml:"is_subscribe"`
+	Attach      string
+	Ignored     string `xml:"-"`
+	internal    string
+}
+
+func TestUnmarshal(t *testing.T) {
+	x := XML(`<xml><out_trade_no>1217752501201407033233368018</out_trade_no><total_fee>88</total_fee>` +
+		`<cash_fee>80</cash_fee><rate>6.5</rate><is_subscribe>true</is_subscribe><attach>深圳分店</attach>` +
+		`<Ignored>x</Ignored><internal>y</internal></xml>`)
+	var o testOrder
+	if err := Unmarshal(x, &o); err != nil {
+		t.Fatal(err)
+	}
+	want := testOrder{OutTradeNo: "1217752501201407033233368018", TotalFee: 88, CashFee: 80, Rate: 6.5, IsSubscribe: true, Attach: "深圳分店"}
+	if o != want {
+		t.Errorf("Unmarshal() = %+v, want %+v", o, want)
+	}
+}
+
+func TestDecodeEmptyAndMissing(t *testing.T) {
+	o := testOrder{TotalFee: 1, OutTradeNo: "keep"}
+	if err := (Map{"total_fee": ""}).Decode(&o); err != nil {
+		t.Fatal(err)
+	}
+	if o.TotalFee != 1 || o.OutTradeNo != "keep" {
+		t.Errorf("empty or missing fields changed the struct: %+v", o)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var o testOrder
+	if err := (Map{"total_fee": "1.5"}).Decode(&o); err == nil {
+		t.Error("want error for malformed integer")
+	}
+	if err := (Map{"is_subscribe": "Y"}).Decode(&o); err == nil {
+		t.Error("want error for malformed bool")
+	}
+	if err := (Map{}).Decode(o); err == nil {
+		t.Error("want error for non-pointer")
+	}
+	var p *testOrder
+	if err := (Map{}).Decode(p); err == nil {
+		t.Error("want error for nil pointer")
+	}
+	var unsupported struct {
+		Detail []string `xml:"detail"`
+	}
+	if err := (Map{"detail": "x"}).Decode(&unsupported); err == nil {
+		t.Error("want error for unsupported field kind")
+	}
+}
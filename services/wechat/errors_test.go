@@ -0,0 +1,64 @@
+package wechat
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCheckResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		res     Map
+		wantErr bool
+	}{
+		{"success", Map{"return_code": SUCCESS, "result_code": SUCCESS}, false},
+		{"no result_code", Map{"return_code": SUCCESS}, false},
+		{"return fail", Map{"return_code": FAIL, "return_msg": "参数格式校验错误"}, true},
+		{"missing return_code", Map{}, true},
+		{"result fail", Map{"return_code": SUCCESS, "result_code": FAIL, "err_code": "ORDERPAID"}, true},
+	}
+	for _, tt := range tests {
+		if err := checkResult(tt.res); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReturnCodeFailResponse(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Map{"return_code": FAIL, "return_msg": "参数格式校验错误"}.ToXML()))
+	})
+	res, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"})
+	if err == nil {
+		t.Fatal("want error for return_code FAIL")
+	}
+	if res.GetString("return_msg") != "参数格式校验错误" {
+		t.Errorf("response not returned with error: %v", res)
+	}
+}
+
+func TestWxError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeSigned(w, successResponse().SetString("result_code", FAIL).
+			SetString("err_code", "ORDERPAID").
+			SetString("err_code_des", "订单已支付"), MD5)
+	})
+	_, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"})
+	var wxErr *WxError
+	if !errors.As(err, &wxErr) {
+		t.Fatalf("err = %v, want *WxError", err)
+	}
+	if !wxErr.IsErrCode("ORDERPAID") || wxErr.IsErrCode("SYSTEMERROR") {
+		t.Errorf("IsErrCode mismatch for %+v", wxErr)
+	}
+	if wxErr.ResultCode != FAIL || wxErr.ErrCodeDes != "订单已支付" {
+		t.Errorf("unexpected error fields: %+v", wxErr)
+	}
+	if got := wxErr.Error(); got != "wechat: result_code=FAIL err_code=ORDERPAID err_code_des=订单已支付" {
+		t.Errorf("Error() = %q", got)
+	}
+	if got := NewWxError(Map{"return_code": FAIL, "return_msg": "签名错误"}).Error(); got != "wechat: return_code=FAIL return_msg=签名错误" {
+		t.Errorf("Error() = %q", got)
+	}
+}
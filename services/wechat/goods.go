@@ -0,0 +1,39 @@
+package wechat
+
+import "encoding/json"
+
+// 单品优惠的商品详情, 序列化为json后作为统一下单的detail字段
+type GoodsDetail struct {
+	CostPrice int64   `json:"cost_price,omitempty"` // 订单原价(分)
+	ReceiptID string  `json:"receipt_id,omitempty"` // 商品小票ID
+	Goods     []Goods `json:"goods_detail"`         // 单品列表
+}
+
+// 单品信息
+type Goods struct {
+	GoodsID      string `json:"goods_id"`                 // 商品编码
+	WxpayGoodsID string `json:"wxpay_goods_id,omitempty"` // 微信侧商品编码
+	GoodsName    string `json:"goods_name,omitempty"`     // 商品名称
+	Quantity     int64  `json:"quantity"`                 // 商品数量
+	Price        int64  `json:"price"`                    // 商品单价(分)
+}
+
+// 添加单品
+func (d *GoodsDetail) AddGood(goodsID, goodsName string, quantity, price int64) *GoodsDetail {
+	d.Goods = append(d.Goods, Goods{
+		GoodsID:   goodsID,
+		GoodsName: goodsName,
+		Quantity:  quantity,
+		Price:     price,
+	})
+	return d
+}
+
+// 序列化为detail字段的值
+func (d *GoodsDetail) JSON() (string, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
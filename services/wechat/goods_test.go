@@ -0,0 +1,45 @@
+package wechat
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGoodsDetailJSON(t *testing.T) {
+	d := &GoodsDetail{CostPrice: 608800, ReceiptID: "wx123"}
+	d.AddGood("商品编码", "iPhone6s 16G", 1, 528800).AddGood("1001", "", 2, 40000)
+	got, err := d.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"cost_price":608800,"receipt_id":"wx123","goods_detail":[` +
+		`{"goods_id":"商品编码","goods_name":"iPhone6s 16G","quantity":1,"price":528800},` +
+		`{"goods_id":"1001","quantity":2,"price":40000}]}`
+	if got != want {
+		t.Errorf("JSON() = %s, want %s", got, want)
+	}
+	if got, _ := new(GoodsDetail).JSON(); got != `{"goods_detail":null}` {
+		t.Errorf("empty JSON() = %s", got)
+	}
+}
+
+func TestUnifiedOrderGoodsDetail(t *testing.T) {
+	var req Map
+	c := newTestAPIClient(t, "/pay/unifiedorder", successResponse(), &req)
+	d := &GoodsDetail{CostPrice: 88}
+	detail, err := d.AddGood("1001", "QQ会员", 1, 88).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UnifiedOrder(orderParams().SetString("trade_type", TradeTypeNative).SetString("detail", detail)); err != nil {
+		t.Fatal(err)
+	}
+	var sent GoodsDetail
+	if err := json.Unmarshal([]byte(req.GetString("detail")), &sent); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&sent, d) || !c.VerifySign(req) {
+		t.Errorf("detail = %+v, want %+v", sent, d)
+	}
+}
@@ -0,0 +1,53 @@
+package wechat
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSetHeader(t *testing.T) {
+	var header http.Header
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		writeSigned(w, successResponse(), MD5)
+	})
+	c.SetHeader("User-Agent", "shop/1.0")
+	c.SetHeader("X-Request-Source", "checkout")
+	if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("User-Agent"); got != "shop/1.0" {
+		t.Errorf("User-Agent = %q, want %q", got, "shop/1.0")
+	}
+	if got := header.Get("X-Request-Source"); got != "checkout" {
+		t.Errorf("X-Request-Source = %q, want %q", got, "checkout")
+	}
+	if got := header.Get("Content-Type"); got != bodyType {
+		t.Errorf("Content-Type = %q, want %q", got, bodyType)
+	}
+
+	c.SetHeader("Content-Type", "text/xml")
+	if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("Content-Type"); got != "text/xml" {
+		t.Errorf("Content-Type = %q, want overridden %q", got, "text/xml")
+	}
+}
+
+func TestSetHeaderOnCopy(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	c.SetHeader("User-Agent", "shop/1.0")
+	clone, err := c.WithSignType(HMACSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone.SetHeader("User-Agent", "shop/2.0")
+	clone.SetHeader("X-Debug", "1")
+	if got := c.header.Get("User-Agent"); got != "shop/1.0" {
+		t.Errorf("original User-Agent = %q, want %q", got, "shop/1.0")
+	}
+	if got := c.header.Get("X-Debug"); got != "" {
+		t.Errorf("original X-Debug = %q, want empty", got)
+	}
+}
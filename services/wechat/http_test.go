@@ -0,0 +1,207 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPStatusError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>" + strings.Repeat("x", 1000) + "</html>"))
+	})
+	_, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"})
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("err = %v, want *HTTPStatusError", err)
+	}
+	if statusErr.StatusCode != http.StatusBadGateway || len(statusErr.Body) != maxErrorBodyLen || !strings.HasPrefix(statusErr.Body, "<html>") {
+		t.Errorf("unexpected error: %+v", statusErr)
+	}
+	if !strings.Contains(err.Error(), "502") {
+		t.Errorf("Error() = %q, want the status code", err.Error())
+	}
+}
+
+// 读取时返回错误并记录是否被关闭的响应体
+type failingBody struct {
+	closed bool
+}
+
+func (b *failingBody) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func (b *failingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestResponseBodyClosedOnReadError(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusInternalServerError} {
+		body := new(failingBody)
+		c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+		c.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: status, Body: body, Header: make(http.Header)}, nil
+		})})
+		if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err == nil {
+			t.Errorf("status %d: want error", status)
+		}
+		if !body.closed {
+			t.Errorf("status %d: response body not closed", status)
+		}
+	}
+}
+
+func TestMalformedResponse(t *testing.T) {
+	for name, body := range map[string]string{
+		"empty":      "",
+		"whitespace": " \r\n\t",
+		"html":       "<html><body>502 Bad Gateway</body></html>",
+		"json":       `{"code":"SYSTEMERROR"}`,
+	} {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		})
+		_, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"})
+		var malformed *MalformedResponseError
+		if !errors.Is(err, ErrEmptyResponse) || !errors.As(err, &malformed) {
+			t.Errorf("%s: err = %v, want ErrEmptyResponse", name, err)
+			continue
+		}
+		if string(malformed.Raw) != body {
+			t.Errorf("%s: Raw = %q, want %q", name, malformed.Raw, body)
+		}
+	}
+}
+
+func TestIsXMLBody(t *testing.T) {
+	for body, want := range map[string]bool{
+		"<xml></xml>":                        true,
+		"\n <xml><a>1</a></xml>":             true,
+		xmlDeclaration + "\n<xml></xml>":     true,
+		`<?xml version="1.0"?><html></html>`: false,
+		"":                                   false,
+		"<XML></XML>":                        false,
+	} {
+		if got := isXMLBody([]byte(body)); got != want {
+			t.Errorf("isXMLBody(%q) = %t, want %t", body, got, want)
+		}
+	}
+}
+
+// 返回一个已关闭的服务地址, 请求该地址会出现网络错误
+func closedServerURL() string {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	return srv.URL
+}
+
+func TestFallbackHosts(t *testing.T) {
+	var reqs []Map
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pay/closeorder" {
+			http.NotFound(w, r)
+			return
+		}
+		reqs = append(reqs, readRequest(t, r))
+		writeSigned(w, successResponse(), MD5)
+	}))
+	defer backup.Close()
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	c.SetBaseURL(closedServerURL())
+	c.SetFallbackHosts(closedServerURL(), backup.URL+"/")
+	if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 1 || !c.VerifySign(reqs[0]) {
+		t.Errorf("backup host received %v", reqs)
+	}
+}
+
+func TestFallbackHostsNotUsedForHTTPErrors(t *testing.T) {
+	var backupCalls int
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupCalls++
+		writeSigned(w, successResponse(), MD5)
+	}))
+	defer backup.Close()
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	})
+	c.SetFallbackHosts(backup.URL)
+	var statusErr *HTTPStatusError
+	if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); !errors.As(err, &statusErr) {
+		t.Errorf("err = %v, want HTTPStatusError", err)
+	}
+	if backupCalls != 0 {
+		t.Errorf("backup host called %d times for an http status error", backupCalls)
+	}
+	// 请求的url不在主域名下时不切换
+	c.SetBaseURL(closedServerURL())
+	if _, err := c.sendFailover(context.Background(), c.client(), "http://127.0.0.1:1/pay/closeorder", ""); err == nil || backupCalls != 0 {
+		t.Errorf("sendFailover() = %v, backup calls = %d", err, backupCalls)
+	}
+}
+
+// 读取超时时请求可能已被处理, 不能切换域名重发, 否则付款码支付等接口会重复扣款
+func TestFallbackHostsNotUsedForReadTimeout(t *testing.T) {
+	var backupCalls int32
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&backupCalls, 1)
+		writeSigned(w, successResponse(), MD5)
+	}))
+	defer backup.Close()
+	done := make(chan struct{})
+	defer close(done)
+	var primaryCalls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryCalls, 1)
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-done:
+		}
+	})
+	if err := c.SetReadTimeout(50); err != nil {
+		t.Fatal(err)
+	}
+	c.SetFallbackHosts(backup.URL)
+	_, err := c.MicroPay(microPayParams())
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("err = %v, want a timeout error", err)
+	}
+	if n := atomic.LoadInt32(&primaryCalls); n != 1 {
+		t.Errorf("primary host called %d times, want 1", n)
+	}
+	if n := atomic.LoadInt32(&backupCalls); n != 0 {
+		t.Errorf("backup host called %d times after a read timeout", n)
+	}
+}
+
+func TestIsDialError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"dial", &url.Error{Op: "Post", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}, true},
+		{"dns", &url.Error{Op: "Post", Err: &net.DNSError{Err: "no such host", Name: "api.mch.weixin.qq.com"}}, true},
+		{"read", &url.Error{Op: "Post", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}, false},
+		{"timeout", &url.Error{Op: "Post", Err: context.DeadlineExceeded}, false},
+		{"http status", &HTTPStatusError{StatusCode: http.StatusBadGateway}, false},
+	}
+	for _, tt := range tests {
+		if got := isDialError(tt.err); got != tt.want {
+			t.Errorf("%s: isDialError() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package wechat
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 获取发起请求的客户端ip, 用作spbill_create_ip
+// 依次读取X-Forwarded-For的第一个地址、X-Real-IP和RemoteAddr
+func ClientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); len(forwarded) > 0 {
+		if ip := stripPort(strings.Split(forwarded, ",")[0]); len(ip) > 0 {
+			return ip
+		}
+	}
+	if ip := stripPort(r.Header.Get("X-Real-IP")); len(ip) > 0 {
+		return ip
+	}
+	return stripPort(r.RemoteAddr)
+}
+
+// 去除地址中的端口和IPv6的方括号
+func stripPort(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
@@ -0,0 +1,37 @@
+package wechat
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		forwarded  string
+		realIP     string
+		remoteAddr string
+		want       string
+	}{
+		{"remote addr", "", "", "123.12.12.123:54321", "123.12.12.123"},
+		{"forwarded first", "14.17.22.52, 10.0.0.1", "10.0.0.2", "10.0.0.3:80", "14.17.22.52"},
+		{"forwarded with port", "14.17.22.52:8080", "", "10.0.0.3:80", "14.17.22.52"},
+		{"real ip", "", " 14.17.22.52 ", "10.0.0.3:80", "14.17.22.52"},
+		{"empty forwarded entry", " , 10.0.0.1", "14.17.22.52", "10.0.0.3:80", "14.17.22.52"},
+		{"ipv6 remote", "", "", "[2001:db8::1]:443", "2001:db8::1"},
+		{"ipv6 bracketed", "[2001:db8::1]", "", "10.0.0.3:80", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/order", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if got := ClientIP(r); got != tt.want {
+			t.Errorf("%s: ClientIP() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package wechat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// 转换为json, 所有的值均为字符串, 如timeStamp不会被转换为数字
+func (m Map) ToJSON() ([]byte, error) {
+	return json.Marshal(map[string]string(m))
+}
+
+// 从json对象创建Map, 数字和bool保留原始的字面值
+func FromJSON(data []byte) (Map, error) {
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	m := make(Map, len(raw))
+	for k, v := range raw {
+		switch value := v.(type) {
+		case string:
+			m.SetString(k, value)
+		case json.Number:
+			m.SetString(k, value.String())
+		case bool:
+			m.SetString(k, strconv.FormatBool(value))
+		case nil:
+			m.SetString(k, "")
+		default:
+			return nil, fmt.Errorf("wechat: unsupported json value for key %s", k)
+		}
+	}
+	return m, nil
+}
+
+// 将v序列化为json后保存, 用于receivers等内嵌json的字段
+func (m Map) SetJSON(k string, v interface{}) (Map, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return m, err
+	}
+	return m.SetString(k, string(data)), nil
+}
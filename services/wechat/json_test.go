@@ -0,0 +1,50 @@
+package wechat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMapJSONRoundTrip(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	params := c.JSAPIPayParams(testPrepayID)
+	data, err := params.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// timeStamp必须是字符串
+	if !strings.Contains(string(data), `"timeStamp":"`+params.GetString("timeStamp")+`"`) {
+		t.Errorf("timeStamp not serialized as a string: %s", data)
+	}
+	got, err := FromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(params) {
+		t.Fatalf("FromJSON() = %v, want %v", got, params)
+	}
+	for k, v := range params {
+		if got.GetString(k) != v {
+			t.Errorf("%s = %q, want %q", k, got.GetString(k), v)
+		}
+	}
+}
+
+func TestFromJSONLiterals(t *testing.T) {
+	m, err := FromJSON([]byte(`{"timeStamp":1414561699,"total_fee":10000000000000001,"rate":0.10,"is_subscribe":true,"attach":null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Map{"timeStamp": "1414561699", "total_fee": "10000000000000001", "rate": "0.10", "is_subscribe": "true", "attach": ""}
+	for k, v := range want {
+		if got, ok := m[k]; !ok || got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if _, err := FromJSON([]byte(`{"receivers":[1,2]}`)); err == nil {
+		t.Error("want error for nested values")
+	}
+	if _, err := FromJSON([]byte(`not json`)); err == nil {
+		t.Error("want error for malformed json")
+	}
+}
@@ -0,0 +1,100 @@
+package wechat
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 记录日志的Logger
+type testLogger struct {
+	url       string
+	req, resp XML
+	err       error
+}
+
+func (l *testLogger) Log(url string, req, resp XML, err error) {
+	l.url, l.req, l.resp, l.err = url, req, resp, err
+}
+
+func TestLogger(t *testing.T) {
+	c := newTestAPIClient(t, "/pay/closeorder", successResponse(), nil)
+	logger := new(testLogger)
+	c.SetLogger(logger)
+	if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(logger.url, "/pay/closeorder") || logger.err != nil {
+		t.Errorf("url = %s, err = %v", logger.url, logger.err)
+	}
+	if req := logger.req.ToMap(); req.GetString("out_trade_no") == "" || req.GetString("sign") != "***" {
+		t.Errorf("logged request = %s", logger.req)
+	}
+	if resp := logger.resp.ToMap(); resp.GetString("return_code") != SUCCESS || resp.GetString("sign") != "***" {
+		t.Errorf("logged response = %s", logger.resp)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	tests := []struct {
+		in, want XML
+	}{
+		{"<xml><sign>\n<![CDATA[C380BEC2BFD727A4B6845133519F3AD6]]>\n</sign><total_fee>1</total_fee></xml>",
+			"<xml><sign>***</sign><total_fee>1</total_fee></xml>"},
+		{"<xml><return_code>SUCCESS</return_code><sandbox_signkey><![CDATA[013467007045764]]></sandbox_signkey></xml>",
+			"<xml><return_code>SUCCESS</return_code><sandbox_signkey>***</sandbox_signkey></xml>"},
+		{"<xml><req_info><![CDATA[T87GAHG17TGAHG1TGHAHAHA1Y1CIOA9UGJH1GAHV871HAGAGQYQQPOOJMXNBCXBVNMNMAJAA]]></req_info><sign_type>MD5</sign_type></xml>",
+			"<xml><req_info>***</req_info><sign_type>MD5</sign_type></xml>"},
+		{"<xml><paySign>A</paySign><key>B</key><signature>C</signature></xml>",
+			"<xml><paySign>***</paySign><key>***</key><signature>C</signature></xml>"},
+	}
+	for _, tt := range tests {
+		if got := redactSecrets(tt.in); got != tt.want {
+			t.Errorf("redactSecrets(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerRedactsSandboxSignKey(t *testing.T) {
+	var requests []Map
+	c := newTestSandboxClient(t, &requests)
+	logger := new(testLogger)
+	c.SetLogger(logger)
+	if _, err := c.GetSandboxSignKey(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(logger.resp), testSandboxKey) || logger.resp.ToMap().GetString("sandbox_signkey") != "***" {
+		t.Errorf("logged response = %s", logger.resp)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		writeSigned(w, successResponse(), MD5)
+	})
+	if c.LastLatency() != 0 {
+		t.Errorf("LastLatency() = %v before any request", c.LastLatency())
+	}
+	var (
+		endpoint string
+		dur      time.Duration
+		calls    int
+	)
+	c.SetMetrics(func(e string, d time.Duration, err error) {
+		endpoint, dur, calls = e, d, calls+1
+		if err != nil {
+			t.Errorf("metrics err = %v", err)
+		}
+	})
+	if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || endpoint != "/pay/closeorder" || dur < 20*time.Millisecond {
+		t.Errorf("metrics = %d calls, %q, %v", calls, endpoint, dur)
+	}
+	if c.LastLatency() != dur {
+		t.Errorf("LastLatency() = %v, want %v", c.LastLatency(), dur)
+	}
+}
@@ -0,0 +1,157 @@
+package wechat
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Map
+		want Map
+	}{
+		{"duplicate sign", Map{"sign": "A", "Sign": "B", "body": "test"}, Map{"sign": "A", "body": "test"}},
+		{"duplicate appid", Map{"appid": testAppID, "AppID": "wx0"}, Map{"appid": testAppID}},
+		{"known field", Map{"Nonce_Str": "abc", "SIGN": "A"}, Map{"nonce_str": "abc", "sign": "A"}},
+		{"duplicate without lowercase", Map{"Attach": "A", "ATTACH": "B"}, Map{"ATTACH": "B"}},
+		{"camel case pay params", Map{"appId": testAppID, "nonceStr": "abc", "timeStamp": "1414561699", "paySign": "A"},
+			Map{"appId": testAppID, "nonceStr": "abc", "timeStamp": "1414561699", "paySign": "A"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.Canonicalize(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Canonicalize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapFloatAndBool(t *testing.T) {
+	m := make(Map)
+	m.SetFloat64("rate", 12.345, 2).SetBool("is_subscribe", true)
+	if m.GetString("rate") != "12.35" || m.GetFloat64("rate") != 12.35 {
+		t.Errorf("rate = %q/%v", m.GetString("rate"), m.GetFloat64("rate"))
+	}
+	if m.GetString("is_subscribe") != "true" || !m.GetBool("is_subscribe") {
+		t.Errorf("is_subscribe = %q", m.GetString("is_subscribe"))
+	}
+	bad := Map{"rate": "12.3.4", "is_subscribe": "Y"}
+	if bad.GetFloat64("rate") != 0 || bad.GetBool("is_subscribe") || bad.GetFloat64("missing") != 0 || bad.GetBool("missing") {
+		t.Error("malformed values should read as zero")
+	}
+}
+
+func TestSetTimeExpire(t *testing.T) {
+	before := time.Now()
+	m := make(Map).SetTimeExpire(30 * time.Minute)
+	start, err := time.ParseInLocation(timeLayout, m.GetString("time_start"), beijing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expire, err := time.ParseInLocation(timeLayout, m.GetString("time_expire"), beijing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.GetString("time_start")) != 14 || expire.Sub(start) != 30*time.Minute {
+		t.Errorf("time_start = %s, time_expire = %s", m.GetString("time_start"), m.GetString("time_expire"))
+	}
+	// 时间按北京时间格式化, 与本地时区无关
+	if d := start.Sub(before.Truncate(time.Second)); d < 0 || d > 2*time.Second {
+		t.Errorf("time_start %s is not the current Beijing time", m.GetString("time_start"))
+	}
+}
+
+func TestMapBytes(t *testing.T) {
+	b := []byte{0x00, 0xff, 'w', 'x'}
+	p := make(Map).SetBytes("req_info", b)
+	if p.GetString("req_info") != "AP93eA==" {
+		t.Errorf("SetBytes stored %q, want AP93eA==", p.GetString("req_info"))
+	}
+	got, err := p.GetBytes("req_info")
+	if err != nil || !reflect.DeepEqual(got, b) {
+		t.Errorf("GetBytes() = %v, %v, want %v", got, err, b)
+	}
+	if _, err := p.SetString("req_info", "not base64!").GetBytes("req_info"); err == nil {
+		t.Error("GetBytes accepted invalid base64")
+	}
+}
+
+func TestClone(t *testing.T) {
+	m := Map{"body": "test", "total_fee": "1"}
+	c := m.Clone()
+	if !reflect.DeepEqual(c, m) {
+		t.Errorf("Clone() = %v, want %v", c, m)
+	}
+	c.SetString("body", "changed").SetString("sign", "A")
+	if m.GetString("body") != "test" || m.ContainsKey("sign") {
+		t.Errorf("modifying the clone changed the original: %v", m)
+	}
+	if c := Map(nil).Clone(); c == nil || len(c) != 0 {
+		t.Errorf("Map(nil).Clone() = %#v, want an empty map", c)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	m := Map{"body": "test", "total_fee": "1"}
+	other := Map{"total_fee": "88", "attach": "A"}
+	if got := m.Merge(other); !reflect.DeepEqual(got, Map{"body": "test", "total_fee": "88", "attach": "A"}) {
+		t.Errorf("Merge() = %v", got)
+	}
+	if !reflect.DeepEqual(other, Map{"total_fee": "88", "attach": "A"}) {
+		t.Errorf("Merge modified other: %v", other)
+	}
+	if got := m.Merge(nil); len(got) != 3 {
+		t.Errorf("Merge(nil) = %v", got)
+	}
+}
+
+func TestMerged(t *testing.T) {
+	m := Map{"body": "test", "total_fee": "1"}
+	got := m.Merged(Map{"total_fee": "88"})
+	if !reflect.DeepEqual(got, Map{"body": "test", "total_fee": "88"}) {
+		t.Errorf("Merged() = %v", got)
+	}
+	if m.GetString("total_fee") != "1" {
+		t.Errorf("Merged modified the receiver: %v", m)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	p := make(Map)
+	if err := p.SetAttach("深圳分店"); err != nil || p.GetAttach() != "深圳分店" {
+		t.Errorf("SetAttach() = %v, GetAttach() = %q", err, p.GetAttach())
+	}
+	if err := p.SetAttach(strings.Repeat("a", maxAttachLen)); err != nil {
+		t.Errorf("SetAttach(127 bytes) = %v", err)
+	}
+	// 长度按字节计算, 43个汉字为129字节
+	if err := p.SetAttach(strings.Repeat("中", 43)); err != ErrAttachTooLong {
+		t.Errorf("SetAttach(129 bytes) = %v, want ErrAttachTooLong", err)
+	}
+	if p.GetAttach() != strings.Repeat("a", maxAttachLen) {
+		t.Error("SetAttach changed attach on error")
+	}
+}
+
+func TestAttachBytes(t *testing.T) {
+	b := []byte{0x01, 0x02, 0xfe, 0xff}
+	p := make(Map)
+	if err := p.SetAttachBytes(b); err != nil {
+		t.Fatal(err)
+	}
+	// 支付结果通知中原样返回
+	notify := XML(p.ToXML()).ToMap()
+	if got, err := notify.GetAttachBytes(); err != nil || !reflect.DeepEqual(got, b) {
+		t.Errorf("GetAttachBytes() = %v, %v, want %v", got, err, b)
+	}
+	// base64编码后为128字节
+	if err := p.SetAttachBytes(make([]byte, 96)); err != ErrAttachTooLong {
+		t.Errorf("SetAttachBytes(96 bytes) = %v, want ErrAttachTooLong", err)
+	}
+	if err := p.SetAttachBytes(make([]byte, 93)); err != nil {
+		t.Errorf("SetAttachBytes(93 bytes) = %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package wechat
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNonceGenerator(t *testing.T) {
+	var req Map
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		req = readRequest(t, r)
+		writeSigned(w, successResponse(), MD5)
+	})
+	c.SetNonceGenerator(func() string { return "FIXEDNONCE" })
+	if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if req.GetString("nonce_str") != "FIXEDNONCE" {
+		t.Errorf("nonce_str = %q, want FIXEDNONCE", req.GetString("nonce_str"))
+	}
+}
+
+func TestDefaultNonce(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	a, b := c.nonce(), c.nonce()
+	if len(a) != 32 || a == b {
+		t.Errorf("nonce() = %q, %q, want distinct 32-char strings", a, b)
+	}
+}
+
+func TestPingUsesNonceGenerator(t *testing.T) {
+	var req Map
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		req = readRequest(t, r)
+		writeSigned(w, successResponse().SetString("result_code", FAIL).SetString("err_code", "ORDERNOTEXIST"), MD5)
+	})
+	c.SetNonceGenerator(func() string { return "0123456789ABCDEFGHIJ" })
+	if err := c.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.GetString("out_trade_no"); got != "PING0123456789ABCDEF" {
+		t.Errorf("out_trade_no = %q, want PING0123456789ABCDEF", got)
+	}
+	if req.GetString("nonce_str") != "0123456789ABCDEFGHIJ" {
+		t.Errorf("nonce_str = %q", req.GetString("nonce_str"))
+	}
+}
+
+func TestV3NonceGenerator(t *testing.T) {
+	key, _ := newTestRSAKey(t)
+	platformKey, platformCert := newTestPlatformCert(t)
+	var auth string
+	srv := newTestV3Server(t, func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		writeV3Signed(w, platformKey, `{"code_url":"weixin://wxpay/bizpayurl/up?pr=NwY5Mz9"}`)
+	})
+	c := NewV3Client(testAppID, testMchID, testSerialNo, key)
+	c.SetBaseURL(srv)
+	c.SetPlatformCertificate(platformCert)
+	c.SetNonceGenerator(func() string { return "FIXEDNONCE" })
+	if _, err := c.NativeTransaction(context.Background(), map[string]interface{}{"description": "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(auth, `nonce_str="FIXEDNONCE"`) {
+		t.Errorf("Authorization = %q, want nonce_str=FIXEDNONCE", auth)
+	}
+}
+
+func TestNonceStrRandom(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		s := nonceStr()
+		if len(s) != 32 || strings.Trim(s, "0123456789ABCDEF") != "" {
+			t.Fatalf("nonceStr() = %q, want 32 uppercase hex characters", s)
+		}
+		if seen[s] {
+			t.Fatalf("nonceStr() repeated %q", s)
+		}
+		seen[s] = true
+	}
+}
@@ -0,0 +1,67 @@
+package wechat
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// 解析支付结果通知并校验签名
+func (c *Client) ParseNotify(r io.Reader) (Map, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	params := XML(body).ToMap()
+	if !c.VerifySign(params) {
+		return nil, ErrInvalidSign
+	}
+	return params, nil
+}
+
+// 生成回复微信支付结果通知的xml
+func NotifyResponse(success bool, msg string) XML {
+	params := make(Map)
+	if success {
+		params.SetString("return_code", SUCCESS)
+	} else {
+		params.SetString("return_code", FAIL)
+	}
+	return params.SetString("return_msg", msg).ToXML()
+}
+
+// 处理支付结果通知的http.HandlerFunc, 校验签名且支付成功(return_code和result_code均为SUCCESS)后调用onPaid,
+// onPaid返回nil时回复SUCCESS, 否则回复FAIL以便微信重新通知
+// 支付失败的通知不调用onPaid, 直接回复SUCCESS; 回复中不包含错误详情, 避免内部信息泄露给调用方
+func (c *Client) NotifyHandler(onPaid func(Map) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		resp := NotifyResponse(true, "OK")
+		params, err := c.ParseNotify(r.Body)
+		if err == nil && params.GetString("return_code") == SUCCESS && params.GetString("result_code") == SUCCESS {
+			err = onPaid(params)
+		}
+		if err != nil {
+			resp = NotifyResponse(false, "FAIL")
+		}
+		w.Header().Set("Content-Type", bodyType)
+		w.Write([]byte(resp))
+	}
+}
+
+// 解密退款结果通知中的req_info, 返回解密后的通知内容
+// req_info使用AES-256-ECB加密, 秘钥为apiKey的MD5值(32位小写), 填充方式为PKCS#7
+func (c *Client) DecryptRefundNotify(m Map) (Map, error) {
+	ciphertext, err := m.GetBytes("req_info")
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum([]byte(c.account.apiKey))
+	plaintext, err := DecryptAESECB(ciphertext, []byte(hex.EncodeToString(sum[:])))
+	if err != nil {
+		return nil, err
+	}
+	return XML(plaintext).ToMap(), nil
+}
@@ -0,0 +1,158 @@
+package wechat
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 构造签名后的支付结果通知
+func newTestNotify(t *testing.T, resultCode string) Map {
+	t.Helper()
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	return c.SetSign(Map{
+		"return_code":    SUCCESS,
+		"result_code":    resultCode,
+		"appid":          testAppID,
+		"mch_id":         testMchID,
+		"nonce_str":      "5d2b6c2a8db53831f7eda20af46e531c",
+		"openid":         "oUpF8uMEb4qRXf22hE3X68TekukE",
+		"out_trade_no":   "1409811653",
+		"total_fee":      "1",
+		"transaction_id": "1004400740201409030005092168",
+	})
+}
+
+// 将通知发送到NotifyHandler, 返回回复的内容
+func postNotify(c *Client, notify Map, onPaid func(Map) error) Map {
+	rec := httptest.NewRecorder()
+	c.NotifyHandler(onPaid)(rec, httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(string(notify.ToXML()))))
+	return XML(rec.Body.String()).ToMap()
+}
+
+func TestParseNotify(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	params, err := c.ParseNotify(strings.NewReader(string(newTestNotify(t, SUCCESS).ToXML())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.GetString("transaction_id") != "1004400740201409030005092168" {
+		t.Errorf("transaction_id = %q", params.GetString("transaction_id"))
+	}
+	tampered := newTestNotify(t, SUCCESS).SetString("total_fee", "100").ToXML()
+	if _, err := c.ParseNotify(strings.NewReader(string(tampered))); err != ErrInvalidSign {
+		t.Errorf("tampered notify err = %v, want ErrInvalidSign", err)
+	}
+}
+
+func TestNotifyHandler(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	var paid Map
+	resp := postNotify(c, newTestNotify(t, SUCCESS), func(m Map) error {
+		paid = m
+		return nil
+	})
+	if paid.GetString("out_trade_no") != "1409811653" {
+		t.Errorf("onPaid not called with the notify, got %v", paid)
+	}
+	if resp.GetString("return_code") != SUCCESS {
+		t.Errorf("return_code = %q, want SUCCESS", resp.GetString("return_code"))
+	}
+}
+
+func TestNotifyHandlerCallbackError(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	resp := postNotify(c, newTestNotify(t, SUCCESS), func(m Map) error {
+		return errors.New("db: connection refused")
+	})
+	if resp.GetString("return_code") != FAIL {
+		t.Errorf("return_code = %q, want FAIL", resp.GetString("return_code"))
+	}
+	if strings.Contains(resp.GetString("return_msg"), "db:") {
+		t.Errorf("return_msg leaks the internal error: %q", resp.GetString("return_msg"))
+	}
+}
+
+func TestNotifyHandlerRejects(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	tests := []struct {
+		name           string
+		notify         Map
+		wantReturnCode string
+	}{
+		{"payment failed", newTestNotify(t, FAIL), SUCCESS},
+		{"invalid sign", newTestNotify(t, SUCCESS).SetString("total_fee", "100"), FAIL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			resp := postNotify(c, tt.notify, func(m Map) error {
+				called = true
+				return nil
+			})
+			if called {
+				t.Error("onPaid called")
+			}
+			if resp.GetString("return_code") != tt.wantReturnCode {
+				t.Errorf("return_code = %q, want %q", resp.GetString("return_code"), tt.wantReturnCode)
+			}
+		})
+	}
+}
+
+func TestNotifyResponse(t *testing.T) {
+	if got := NotifyResponse(true, "OK").ToMap(); got.GetString("return_code") != SUCCESS || got.GetString("return_msg") != "OK" {
+		t.Errorf("NotifyResponse(true) = %v", got)
+	}
+	if got := NotifyResponse(false, "签名失败").ToMap(); got.GetString("return_code") != FAIL || got.GetString("return_msg") != "签名失败" {
+		t.Errorf("NotifyResponse(false) = %v", got)
+	}
+}
+
+// 按退款结果通知的方式加密req_info: AES-256-ECB, 秘钥为apiKey的MD5值(32位小写)
+func encryptReqInfo(t *testing.T, apiKey string, plaintext []byte) []byte {
+	t.Helper()
+	sum := md5.Sum([]byte(apiKey))
+	ciphertext, err := EncryptAESECB(plaintext, []byte(hex.EncodeToString(sum[:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ciphertext
+}
+
+func TestDecryptRefundNotify(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	info := Map{"out_refund_no": "1217752501201407033233368018", "refund_status": SUCCESS, "refund_fee": "100"}
+	notify := Map{"return_code": SUCCESS, "appid": testAppID, "mch_id": testMchID}.
+		SetBytes("req_info", encryptReqInfo(t, testAPIKey, []byte(info.ToXML())))
+	got, err := c.DecryptRefundNotify(notify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, info) {
+		t.Errorf("DecryptRefundNotify() = %v, want %v", got, info)
+	}
+}
+
+func TestDecryptRefundNotifyErrors(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	tests := []struct {
+		name    string
+		reqInfo string
+	}{
+		{"invalid base64", "not base64!"},
+		{"empty", ""},
+		{"wrong length", "AP93eA=="},
+		{"wrong key", Map{}.SetBytes("req_info", encryptReqInfo(t, "wrongkey", []byte("<root></root>"))).GetString("req_info")},
+	}
+	for _, tt := range tests {
+		if m, err := c.DecryptRefundNotify(Map{"req_info": tt.reqInfo}); err == nil {
+			t.Errorf("%s: DecryptRefundNotify() = %v, want an error", tt.name, m)
+		}
+	}
+}
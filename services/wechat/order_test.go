@@ -0,0 +1,312 @@
+package wechat
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// 创建只响应path的测试客户端, 收到的请求写入req
+func newTestAPIClient(t *testing.T, path string, res Map, req *Map) *Client {
+	t.Helper()
+	return newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		if req != nil {
+			*req = readRequest(t, r)
+		}
+		writeSigned(w, res, MD5)
+	})
+}
+
+func TestCloseOrder(t *testing.T) {
+	var req Map
+	c := newTestAPIClient(t, "/pay/closeorder", successResponse(), &req)
+	if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if req.GetString("out_trade_no") != "1217752501201407033233368018" || !c.VerifySign(req) {
+		t.Errorf("unexpected request: %v", req)
+	}
+	if _, err := c.CloseOrder(Map{}); err == nil {
+		t.Error("want error without out_trade_no")
+	}
+}
+
+func TestCloseOrderSandbox(t *testing.T) {
+	c := newTestAPIClient(t, "/sandboxnew/pay/closeorder", successResponse(), nil)
+	c.account = NewAccount(testAppID, testMchID, testAPIKey, true)
+	if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 统一下单的必填参数
+func orderParams() Map {
+	return Map{"body": "腾讯充值中心-QQ会员充值", "out_trade_no": "20150806125346", "total_fee": "88",
+		"spbill_create_ip": "123.12.12.123", "notify_url": "https://www.weixin.qq.com/wxpay/pay.php"}
+}
+
+func TestNativeOrder(t *testing.T) {
+	var req Map
+	res := successResponse().SetString("code_url", "weixin://wxpay/bizpayurl/up?pr=NwY5Mz9&groupid=00")
+	c := newTestAPIClient(t, "/pay/unifiedorder", res, &req)
+	codeURL, err := c.NativeOrder(orderParams().SetString("product_id", "12235413214070356458058"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codeURL != "weixin://wxpay/bizpayurl/up?pr=NwY5Mz9&groupid=00" {
+		t.Errorf("code_url = %q", codeURL)
+	}
+	if req.GetString("trade_type") != TradeTypeNative {
+		t.Errorf("trade_type = %q, want NATIVE", req.GetString("trade_type"))
+	}
+	if _, err := c.NativeOrder(orderParams()); err == nil {
+		t.Error("want error without product_id")
+	}
+}
+
+func TestNativeOrderMissingCodeURL(t *testing.T) {
+	c := newTestAPIClient(t, "/pay/unifiedorder", successResponse(), nil)
+	if _, err := c.NativeOrder(orderParams().SetString("product_id", "12235413214070356458058")); err == nil {
+		t.Error("want error when code_url is missing")
+	}
+}
+
+func TestH5Order(t *testing.T) {
+	var req Map
+	res := successResponse().SetString("mweb_url", "https://wx.tenpay.com/cgi-bin/mmpayweb-bin/checkmweb?prepay_id=wx2016121516420242444321ca0631331346")
+	c := newTestAPIClient(t, "/pay/unifiedorder", res, &req)
+	sceneInfo := `{"h5_info": {"type":"Wap","wap_url": "https://pay.qq.com","wap_name": "腾讯充值"}}`
+	mwebURL, err := c.H5Order(orderParams().SetString("scene_info", sceneInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mwebURL != res.GetString("mweb_url") {
+		t.Errorf("mweb_url = %q", mwebURL)
+	}
+	if req.GetString("trade_type") != TradeTypeMWeb || req.GetString("scene_info") != sceneInfo {
+		t.Errorf("unexpected request: %v", req)
+	}
+	if _, err := c.H5Order(orderParams()); err == nil {
+		t.Error("want error without scene_info")
+	}
+}
+
+func TestReverse(t *testing.T) {
+	var req Map
+	c := newTestCertClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/secapi/pay/reverse" {
+			http.NotFound(w, r)
+			return
+		}
+		req = readRequest(t, r)
+		writeSigned(w, successResponse().SetString("recall", "N"), MD5)
+	})
+	res, err := c.Reverse(Map{"out_trade_no": "1217752501201407033233368018"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetString("recall") != "N" || !c.VerifySign(req) {
+		t.Errorf("unexpected response %v or request %v", res, req)
+	}
+	if _, err := c.Reverse(Map{}); err == nil {
+		t.Error("want error without transaction_id or out_trade_no")
+	}
+}
+
+func TestReverseMissingCert(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("reverse sent without a certificate")
+	})
+	if _, err := c.Reverse(Map{"out_trade_no": "1217752501201407033233368018"}); err != ErrMissingCert {
+		t.Errorf("err = %v, want ErrMissingCert", err)
+	}
+}
+
+func TestUnifiedOrderRaw(t *testing.T) {
+	var body []byte
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		res := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false)).SetSign(successResponse().SetString("prepay_id", testPrepayID))
+		body = []byte(xmlDeclaration + string(res.ToXML()))
+		w.Write(body)
+	})
+	res, raw, err := c.UnifiedOrderRaw(orderParams().SetString("trade_type", TradeTypeNative))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != string(body) || res.GetString("prepay_id") != testPrepayID {
+		t.Errorf("raw = %s, res = %v", raw, res)
+	}
+}
+
+func TestOrderQueryRawOnError(t *testing.T) {
+	body := Map{"return_code": FAIL, "return_msg": "签名错误"}.ToXML()
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+	_, raw, err := c.OrderQueryRaw(Map{"out_trade_no": "1217752501201407033233368018"})
+	if err == nil {
+		t.Fatal("want error for return_code FAIL")
+	}
+	if raw != body {
+		t.Errorf("raw = %s, want %s", raw, body)
+	}
+}
+
+func TestEnsureOrderQueriesExisting(t *testing.T) {
+	var query Map
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pay/unifiedorder":
+			writeSigned(w, successResponse().SetString("result_code", FAIL).SetString("err_code", "ORDERPAID"), MD5)
+		case "/pay/orderquery":
+			query = readRequest(t, r)
+			writeSigned(w, successResponse().SetString("trade_state", TradeStateSuccess), MD5)
+		}
+	})
+	res, err := c.EnsureOrder(orderParams().SetString("trade_type", TradeTypeNative))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetString("trade_state") != TradeStateSuccess || query.GetString("out_trade_no") != "20150806125346" {
+		t.Errorf("res = %v, query = %v", res, query)
+	}
+}
+
+func TestEnsureOrderOtherError(t *testing.T) {
+	c := newTestAPIClient(t, "/pay/unifiedorder", successResponse().SetString("result_code", FAIL).SetString("err_code", "NOTENOUGH"), nil)
+	_, err := c.EnsureOrder(orderParams().SetString("trade_type", TradeTypeNative))
+	if wxErr, ok := err.(*WxError); !ok || !wxErr.IsErrCode("NOTENOUGH") {
+		t.Errorf("err = %v, want NOTENOUGH", err)
+	}
+}
+
+func microPayParams() Map {
+	return Map{"auth_code": "120061098828009406", "body": "image形象店-深圳腾大-QQ公仔", "out_trade_no": "1415757673",
+		"total_fee": "1", "spbill_create_ip": "14.17.22.52"}
+}
+
+func TestMicroPay(t *testing.T) {
+	var req Map
+	c := newTestAPIClient(t, "/pay/micropay", successResponse().SetString("transaction_id", "1217752501201407033233368018"), &req)
+	res, err := c.MicroPay(microPayParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetString("transaction_id") == "" || req.GetString("auth_code") != "120061098828009406" || !c.VerifySign(req) {
+		t.Errorf("res = %v, req = %v", res, req)
+	}
+	if _, err := c.MicroPay(microPayParams().SetString("auth_code", "")); err == nil {
+		t.Error("want error without auth_code")
+	}
+	if _, err := c.MicroPay(microPayParams().SetString("total_fee", "0")); err == nil {
+		t.Error("want error for zero total_fee")
+	}
+}
+
+func TestMicroPayUserPaying(t *testing.T) {
+	c := newTestAPIClient(t, "/pay/micropay", successResponse().SetString("result_code", FAIL).SetString("err_code", "USERPAYING"), nil)
+	_, err := c.MicroPay(microPayParams())
+	if wxErr, ok := err.(*WxError); !ok || !wxErr.IsErrCode("USERPAYING") {
+		t.Errorf("err = %v, want USERPAYING", err)
+	}
+}
+
+func TestSubMerchantFields(t *testing.T) {
+	var req Map
+	c := newTestAPIClient(t, "/pay/orderquery", successResponse(), &req)
+	c.account = NewSubMerchantAccount(testAppID, testMchID, testAPIKey, "wx8888888888888888", "1900000109", false)
+	if _, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if req.GetString("sub_appid") != "wx8888888888888888" || req.GetString("sub_mch_id") != "1900000109" || req.GetString("mch_id") != testMchID {
+		t.Errorf("unexpected request: %v", req)
+	}
+	if !c.VerifySign(req) {
+		t.Error("sub merchant fields not covered by the sign")
+	}
+	if c.VerifySign(req.Clone().SetString("sub_mch_id", "1900000110")) {
+		t.Error("sign does not cover sub_mch_id")
+	}
+}
+
+func TestSubMerchantWithoutSubAppID(t *testing.T) {
+	var req Map
+	c := newTestAPIClient(t, "/pay/orderquery", successResponse(), &req)
+	c.account = NewSubMerchantAccount(testAppID, testMchID, testAPIKey, "", "1900000109", false)
+	if _, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if req.ContainsKey("sub_appid") || req.GetString("sub_mch_id") != "1900000109" {
+		t.Errorf("unexpected request: %v", req)
+	}
+}
+
+func TestRequestDoesNotModifyParams(t *testing.T) {
+	res := successResponse().SetString("code_url", "weixin://wxpay/bizpayurl/up?pr=NwY5Mz9&groupid=00").
+		SetString("mweb_url", "https://wx.tenpay.com/cgi-bin/mmpayweb-bin/checkmweb?prepay_id=wx2016121516420242444321ca0631331346").
+		SetString("payment_no", "1000018301201505190181489473")
+	c := newTestCertClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeSigned(w, res, MD5)
+	})
+	tests := []struct {
+		name   string
+		params Map
+		call   func(Map) error
+	}{
+		{"UnifiedOrder", orderParams().SetString("trade_type", TradeTypeNative), func(p Map) error { _, err := c.UnifiedOrder(p); return err }},
+		{"NativeOrder", orderParams().SetString("product_id", "12235413214070356458058"), func(p Map) error { _, err := c.NativeOrder(p); return err }},
+		{"H5Order", orderParams().SetString("scene_info", `{"h5_info":{"type":"Wap"}}`), func(p Map) error { _, err := c.H5Order(p); return err }},
+		{"OrderQuery", Map{"out_trade_no": " 20150806125346 "}, func(p Map) error { _, err := c.OrderQuery(p); return err }},
+		{"CloseOrder", Map{"out_trade_no": "20150806125346"}, func(p Map) error { _, err := c.CloseOrder(p); return err }},
+		{"Transfer", transferParams(), func(p Map) error { _, err := c.Transfer(p); return err }},
+	}
+	for _, tt := range tests {
+		want := tt.params.Clone()
+		if err := tt.call(tt.params); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.params, want) {
+			t.Errorf("%s modified params: %v, want %v", tt.name, tt.params, want)
+		}
+	}
+}
+
+func TestValidateNotifyURL(t *testing.T) {
+	tests := []struct {
+		notifyURL string
+		valid     bool
+	}{
+		{"https://www.weixin.qq.com/wxpay/pay.php", true},
+		{"http://www.weixin.qq.com/wxpay/pay.php", true},
+		{"", false},
+		{"/wxpay/notify", false},
+		{"www.weixin.qq.com/wxpay/pay.php", false},
+		{"ftp://www.weixin.qq.com/wxpay/pay.php", false},
+		{"https:///wxpay/pay.php", false},
+		{"https://www.weixin.qq.com/%zz", false},
+	}
+	for _, tt := range tests {
+		if err := validateNotifyURL(Map{"notify_url": tt.notifyURL}); (err == nil) != tt.valid {
+			t.Errorf("validateNotifyURL(%q) = %v, want valid %t", tt.notifyURL, err, tt.valid)
+		}
+	}
+}
+
+func TestUnifiedOrderInvalidNotifyURL(t *testing.T) {
+	var calls int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeSigned(w, successResponse(), MD5)
+	})
+	if _, err := c.UnifiedOrder(orderParams().SetString("trade_type", TradeTypeApp).SetString("notify_url", "/wxpay/notify")); err == nil {
+		t.Error("want error for relative notify_url")
+	}
+	if calls != 0 {
+		t.Errorf("sent %d requests with an invalid notify_url", calls)
+	}
+}
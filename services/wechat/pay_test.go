@@ -0,0 +1,61 @@
+package wechat
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPrepayID = "wx201410272009395522657a690389285100"
+
+func TestJSAPIPayParams(t *testing.T) {
+	for _, signType := range []string{MD5, HMACSHA256} {
+		c, err := NewClientWithSignType(NewAccount(testAppID, testMchID, testAPIKey, false), signType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := c.JSAPIPayParams(testPrepayID)
+		for _, k := range []string{"appId", "nonceStr", "package", "signType", "timeStamp", "paySign"} {
+			if params.GetString(k) == "" {
+				t.Errorf("%s: %s missing", signType, k)
+			}
+		}
+		if params.GetString("package") != "prepay_id="+testPrepayID || params.GetString("signType") != signType {
+			t.Errorf("%s: unexpected params: %v", signType, params)
+		}
+		unsigned := params.Clone()
+		delete(unsigned, "paySign")
+		if sign := c.Sign(unsigned); sign != params.GetString("paySign") {
+			t.Errorf("%s: paySign = %s, want %s", signType, params.GetString("paySign"), sign)
+		}
+	}
+}
+
+func TestAppPayParams(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	params := c.AppPayParams(testPrepayID)
+	if len(params) != 7 {
+		t.Errorf("got %d fields, want 7: %v", len(params), params)
+	}
+	want := Map{"appid": testAppID, "partnerid": testMchID, "prepayid": testPrepayID, "package": "Sign=WXPay"}
+	for k, v := range want {
+		if params.GetString(k) != v {
+			t.Errorf("%s = %q, want %q", k, params.GetString(k), v)
+		}
+	}
+	if params.GetString("noncestr") == "" || params.GetInt64("timestamp") == 0 {
+		t.Errorf("noncestr or timestamp missing: %v", params)
+	}
+	if !c.VerifySign(params) {
+		t.Error("sign does not validate")
+	}
+}
+
+func TestBrandWCPayRequest(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	params := c.BrandWCPayRequest(testPrepayID)
+	keys := params.sortedKeys()
+	want := []string{"appId", "nonceStr", "package", "paySign", "signType", "timeStamp"}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
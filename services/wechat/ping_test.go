@@ -0,0 +1,44 @@
+package wechat
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	var req Map
+	c := newTestAPIClient(t, "/pay/orderquery", successResponse().SetString("result_code", FAIL).SetString("err_code", "ORDERNOTEXIST"), &req)
+	if err := c.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if out := req.GetString("out_trade_no"); len(out) != 20 || out[:4] != "PING" {
+		t.Errorf("out_trade_no = %q", out)
+	}
+}
+
+func TestPingErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr error
+	}{
+		{"wrong key", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(Map{"return_code": FAIL, "return_msg": "签名错误"}.ToXML()))
+		}, nil},
+		{"bad response sign", func(w http.ResponseWriter, r *http.Request) {
+			res := NewClient(NewAccount(testAppID, testMchID, "00000000000000000000000000000000", false)).SetSign(successResponse())
+			w.Write([]byte(res.ToXML()))
+		}, ErrInvalidSign},
+		{"gateway error", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}, nil},
+	}
+	for _, tt := range tests {
+		c := newTestClient(t, tt.handler)
+		err := c.Ping()
+		if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+			t.Errorf("%s: Ping() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
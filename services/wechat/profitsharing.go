@@ -0,0 +1,81 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// 分账接收方
+type ProfitSharingReceiver struct {
+	Type        string `json:"type"`                  // 分账接收方类型, MERCHANT_ID或PERSONAL_OPENID
+	Account     string `json:"account"`               // 分账接收方帐号
+	Amount      int64  `json:"amount,omitempty"`      // 分账金额(分)
+	Description string `json:"description,omitempty"` // 分账描述
+}
+
+// 校验内嵌json的字段
+func requireJSONParam(params Map, key string) error {
+	if err := requireParams(params, key); err != nil {
+		return err
+	}
+	if !json.Valid([]byte(params.GetString(key))) {
+		return fmt.Errorf("wechat: param %s is not valid json", key)
+	}
+	return nil
+}
+
+// 发送分账相关的请求, 分账接口只支持HMAC-SHA256签名并需要商户证书
+func (c *Client) postProfitSharing(url string, params Map) (Map, error) {
+	h, err := c.certHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	params, err = c.fillRequestDataWith(params, HMACSHA256)
+	if err != nil {
+		return nil, err
+	}
+	return c.doPostXML(context.Background(), h, c.apiURL(url, url), params)
+}
+
+// 请求单次分账(需要商户证书)
+// receivers为json数组, 可以通过params.SetJSON("receivers", []ProfitSharingReceiver{...})设置
+func (c *Client) ProfitSharing(params Map) (Map, error) {
+	if err := requireParams(params, "transaction_id", "out_order_no"); err != nil {
+		return nil, err
+	}
+	if err := requireJSONParam(params, "receivers"); err != nil {
+		return nil, err
+	}
+	return c.postProfitSharing(ProfitSharingUrl, params)
+}
+
+// 添加分账接收方, 只支持HMAC-SHA256签名
+// receiver为json对象, 包含type、account、name、relation_type等字段
+func (c *Client) AddProfitSharingReceiver(params Map) (Map, error) {
+	if err := requireJSONParam(params, "receiver"); err != nil {
+		return nil, err
+	}
+	// 添加分账接收方不需要商户证书
+	url := c.apiURL(ProfitSharingAddReceiverUrl, ProfitSharingAddReceiverUrl)
+	params, err := c.fillRequestDataWith(params, HMACSHA256)
+	if err != nil {
+		return nil, err
+	}
+	return c.postXML(context.Background(), url, params)
+}
+
+// 删除分账接收方, 只支持HMAC-SHA256签名
+// receiver为json对象, 包含type和account字段
+func (c *Client) RemoveProfitSharingReceiver(params Map) (Map, error) {
+	if err := requireJSONParam(params, "receiver"); err != nil {
+		return nil, err
+	}
+	// 删除分账接收方不需要商户证书
+	url := c.apiURL(ProfitSharingRemoveReceiverUrl, ProfitSharingRemoveReceiverUrl)
+	params, err := c.fillRequestDataWith(params, HMACSHA256)
+	if err != nil {
+		return nil, err
+	}
+	return c.postXML(context.Background(), url, params)
+}
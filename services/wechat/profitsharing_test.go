@@ -0,0 +1,114 @@
+package wechat
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProfitSharingReceiverHMACResponse(t *testing.T) {
+	var req Map
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		req = readRequest(t, r)
+		// 微信的响应不携带sign_type, 使用与请求相同的HMAC-SHA256签名
+		writeSigned(w, successResponse(), HMACSHA256)
+	})
+	receiver := `{"type":"MERCHANT_ID","account":"190001001","relation_type":"SERVICE_PROVIDER"}`
+	if _, err := c.AddProfitSharingReceiver(Map{"receiver": receiver}); err != nil {
+		t.Fatalf("AddProfitSharingReceiver: %v", err)
+	}
+	if req.GetString("sign_type") != HMACSHA256 {
+		t.Errorf("sign_type = %q, want %q", req.GetString("sign_type"), HMACSHA256)
+	}
+	if req.GetString("receiver") != receiver {
+		t.Errorf("receiver = %q, want %q", req.GetString("receiver"), receiver)
+	}
+	if _, err := c.RemoveProfitSharingReceiver(Map{"receiver": receiver}); err != nil {
+		t.Fatalf("RemoveProfitSharingReceiver: %v", err)
+	}
+}
+
+func TestProfitSharing(t *testing.T) {
+	var req Map
+	c := newTestCertClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/secapi/pay/profitsharing" {
+			http.NotFound(w, r)
+			return
+		}
+		req = readRequest(t, r)
+		writeSigned(w, successResponse().SetString("order_id", "3008450740201411110007820472"), HMACSHA256)
+	})
+	params, err := Map{"transaction_id": "4208450740201411110007820472", "out_order_no": "P20150806125346"}.
+		SetJSON("receivers", []ProfitSharingReceiver{{Type: "MERCHANT_ID", Account: "190001001", Amount: 100, Description: "分到商户"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.ProfitSharing(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetString("order_id") != "3008450740201411110007820472" {
+		t.Errorf("unexpected response: %v", res)
+	}
+	want := `[{"type":"MERCHANT_ID","account":"190001001","amount":100,"description":"分到商户"}]`
+	if req.GetString("receivers") != want || req.GetString("sign_type") != HMACSHA256 || !c.verifySignWith(req, HMACSHA256) {
+		t.Errorf("unexpected request: %v", req)
+	}
+}
+
+func TestProfitSharingValidates(t *testing.T) {
+	var calls int
+	c := newTestCertClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+	tests := []struct {
+		name   string
+		params Map
+	}{
+		{"missing out_order_no", Map{"transaction_id": "4208450740201411110007820472", "receivers": "[]"}},
+		{"missing receivers", Map{"transaction_id": "4208450740201411110007820472", "out_order_no": "P20150806125346"}},
+		{"invalid receivers", Map{"transaction_id": "4208450740201411110007820472", "out_order_no": "P20150806125346", "receivers": "[{"}},
+	}
+	for _, tt := range tests {
+		if _, err := c.ProfitSharing(tt.params); err == nil {
+			t.Errorf("%s: want an error", tt.name)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("sent %d invalid requests", calls)
+	}
+	noCert := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	if _, err := noCert.ProfitSharing(Map{"transaction_id": "4208450740201411110007820472", "out_order_no": "P20150806125346", "receivers": "[]"}); err != ErrMissingCert {
+		t.Errorf("err = %v, want ErrMissingCert", err)
+	}
+}
+
+func TestProfitSharingReceiverEndpoints(t *testing.T) {
+	var paths []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		writeSigned(w, successResponse(), HMACSHA256)
+	})
+	receiver := `{"type":"PERSONAL_OPENID","account":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"}`
+	if _, err := c.AddProfitSharingReceiver(Map{"receiver": receiver}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.RemoveProfitSharingReceiver(Map{"receiver": receiver}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/pay/profitsharingaddreceiver", "/pay/profitsharingremovereceiver"}
+	if len(paths) != 2 || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("paths = %q, want %q", paths, want)
+	}
+	// receiver必须是json
+	for _, params := range []Map{{}, {"receiver": `{"type":"MERCHANT_ID"`}} {
+		if _, err := c.AddProfitSharingReceiver(params); err == nil {
+			t.Errorf("AddProfitSharingReceiver(%v) = nil error", params)
+		}
+		if _, err := c.RemoveProfitSharingReceiver(params); err == nil {
+			t.Errorf("RemoveProfitSharingReceiver(%v) = nil error", params)
+		}
+	}
+	if len(paths) != 2 {
+		t.Errorf("sent %d requests, want 2", len(paths))
+	}
+}
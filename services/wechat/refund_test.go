@@ -0,0 +1,77 @@
+package wechat
+
+import (
+	"net/http"
+	"testing"
+)
+
+func refundParams() Map {
+	return Map{"out_trade_no": "1217752501201407033233368018", "out_refund_no": "1217752501201407033233368019", "total_fee": "100", "refund_fee": "100"}
+}
+
+func TestRefund(t *testing.T) {
+	var req Map
+	c := newTestCertClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/secapi/pay/refund" {
+			http.NotFound(w, r)
+			return
+		}
+		req = readRequest(t, r)
+		writeSigned(w, successResponse().SetString("refund_id", "2008450740201411110000174436"), MD5)
+	})
+	res, err := c.Refund(refundParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetString("refund_id") != "2008450740201411110000174436" {
+		t.Errorf("unexpected response: %v", res)
+	}
+	if req.GetString("out_refund_no") != "1217752501201407033233368019" || req.GetString("mch_id") != testMchID {
+		t.Errorf("unexpected request: %v", req)
+	}
+	if !c.VerifySign(req) {
+		t.Error("request sign invalid")
+	}
+}
+
+func TestRefundMissingCert(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("refund sent without a certificate")
+	})
+	if _, err := c.Refund(refundParams()); err != ErrMissingCert {
+		t.Errorf("err = %v, want ErrMissingCert", err)
+	}
+}
+
+func TestRefundPresentsCertificate(t *testing.T) {
+	certPEM, keyPEM := newTestCertPEM(t)
+	account := NewAccountWithPEM(testAppID, testMchID, testAPIKey, certPEM, keyPEM, false)
+	c := newTestTLSClient(t, account, func(w http.ResponseWriter, r *http.Request) {
+		if certs := r.TLS.PeerCertificates; len(certs) == 0 || certs[0].Subject.CommonName != testMchID {
+			t.Error("merchant certificate not presented")
+		}
+		writeSigned(w, successResponse(), MD5)
+	})
+	if _, err := c.Refund(refundParams()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefundQuery(t *testing.T) {
+	var req Map
+	res := successResponse().SetString("refund_count", "1").SetString("refund_status_0", "SUCCESS")
+	c := newTestAPIClient(t, "/pay/refundquery", res, &req)
+	got, err := c.RefundQuery(Map{"out_refund_no": "1217752501201407033233368019"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetString("refund_status_0") != "SUCCESS" {
+		t.Errorf("unexpected response: %v", got)
+	}
+	if req.GetString("out_refund_no") != "1217752501201407033233368019" || !c.VerifySign(req) {
+		t.Errorf("unexpected request: %v", req)
+	}
+	if _, err := c.RefundQuery(Map{"total_fee": "100"}); err == nil {
+		t.Error("want error without any refund or order id")
+	}
+}
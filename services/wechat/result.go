@@ -0,0 +1,109 @@
+package wechat
+
+// 交易类型, 对应trade_type字段
+const (
+	TradeTypeJSAPI    = "JSAPI"    // 公众号支付、小程序支付
+	TradeTypeNative   = "NATIVE"   // Native支付
+	TradeTypeApp      = "APP"      // APP支付
+	TradeTypeMWeb     = "MWEB"     // H5支付
+	TradeTypeMicroPay = "MICROPAY" // 付款码支付
+)
+
+// 交易状态, 对应查询订单结果中的trade_state字段
+const (
+	TradeStateSuccess    = "SUCCESS"    // 支付成功
+	TradeStateRefund     = "REFUND"     // 转入退款
+	TradeStateNotPay     = "NOTPAY"     // 未支付
+	TradeStateClosed     = "CLOSED"     // 已关闭
+	TradeStateRevoked    = "REVOKED"    // 已撤销(付款码支付)
+	TradeStateUserPaying = "USERPAYING" // 用户支付中(付款码支付)
+	TradeStatePayError   = "PAYERROR"   // 支付失败
+)
+
+// 交易状态是否为终态, NOTPAY和USERPAYING之外的状态均为终态
+func IsFinalTradeState(state string) bool {
+	switch state {
+	case TradeStateSuccess, TradeStateRefund, TradeStateClosed, TradeStateRevoked, TradeStatePayError:
+		return true
+	}
+	return false
+}
+
+// 统一下单结果
+type UnifiedOrderResult struct {
+	ReturnCode string `xml:"return_code"`  // 通信标识
+	ResultCode string `xml:"result_code"`  // 业务结果
+	PrepayID   string `xml:"prepay_id"`    // 预支付交易会话标识
+	TradeType  string `xml:"trade_type"`   // 交易类型
+	CodeURL    string `xml:"code_url"`     // 二维码链接(Native支付)
+	MwebURL    string `xml:"mweb_url"`     // 支付跳转链接(H5支付)
+	ErrCode    string `xml:"err_code"`     // 错误代码
+	ErrCodeDes string `xml:"err_code_des"` // 错误代码描述
+}
+
+// 统一下单, 返回结构化的结果
+// 业务失败时同时返回已解析的结果和*WxError
+func (c *Client) UnifiedOrderTyped(params Map) (*UnifiedOrderResult, error) {
+	res, err := c.UnifiedOrder(params)
+	if res == nil {
+		return nil, err
+	}
+	result := new(UnifiedOrderResult)
+	if decodeErr := res.Decode(result); decodeErr != nil {
+		return nil, decodeErr
+	}
+	return result, err
+}
+
+// 查询订单结果
+type OrderQueryResult struct {
+	ReturnCode     string `xml:"return_code"`      // 通信标识
+	ResultCode     string `xml:"result_code"`      // 业务结果
+	ErrCode        string `xml:"err_code"`         // 错误代码
+	ErrCodeDes     string `xml:"err_code_des"`     // 错误代码描述
+	TradeState     string `xml:"trade_state"`      // 交易状态
+	TradeStateDesc string `xml:"trade_state_desc"` // 交易状态描述
+	TradeType      string `xml:"trade_type"`       // 交易类型
+	TransactionID  string `xml:"transaction_id"`   // 微信支付订单号
+	OutTradeNo     string `xml:"out_trade_no"`     // 商户订单号
+	TotalFee       int64  `xml:"total_fee"`        // 订单金额(分)
+	CashFee        int64  `xml:"cash_fee"`         // 现金支付金额(分)
+	BankType       string `xml:"bank_type"`        // 付款银行
+	TimeEnd        string `xml:"time_end"`         // 支付完成时间
+	OpenID         string `xml:"openid"`           // 用户标识
+	Attach         string `xml:"attach"`           // 附加数据
+}
+
+// 是否已支付
+func (r *OrderQueryResult) IsPaid() bool {
+	return r.TradeState == TradeStateSuccess
+}
+
+// 是否已关闭
+func (r *OrderQueryResult) IsClosed() bool {
+	return r.TradeState == TradeStateClosed
+}
+
+// 交易状态是否为终态
+func (r *OrderQueryResult) IsFinal() bool {
+	return IsFinalTradeState(r.TradeState)
+}
+
+// 是否已转入退款
+func (r *OrderQueryResult) IsRefunded() bool {
+	return r.TradeState == TradeStateRefund
+}
+
+// 查询订单, 返回结构化的结果
+// 业务失败时同时返回已解析的结果和*WxError
+func (c *Client) OrderQueryTyped(params Map) (*OrderQueryResult, error) {
+	res, err := c.OrderQuery(params)
+	if res == nil {
+		return nil, err
+	}
+	result := new(OrderQueryResult)
+	if decodeErr := res.Decode(result); decodeErr != nil {
+		return nil, decodeErr
+	}
+	return result, err
+}
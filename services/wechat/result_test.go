@@ -0,0 +1,95 @@
+package wechat
+
+import (
+	"testing"
+)
+
+func TestUnifiedOrderTyped(t *testing.T) {
+	res := successResponse().SetString("prepay_id", testPrepayID).SetString("trade_type", TradeTypeNative).
+		SetString("code_url", "weixin://wxpay/bizpayurl/up?pr=NwY5Mz9&groupid=00")
+	c := newTestAPIClient(t, "/pay/unifiedorder", res, nil)
+	result, err := c.UnifiedOrderTyped(orderParams().SetString("trade_type", TradeTypeNative))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := UnifiedOrderResult{ReturnCode: SUCCESS, ResultCode: SUCCESS, PrepayID: testPrepayID, TradeType: TradeTypeNative,
+		CodeURL: "weixin://wxpay/bizpayurl/up?pr=NwY5Mz9&groupid=00"}
+	if *result != want {
+		t.Errorf("result = %+v, want %+v", *result, want)
+	}
+}
+
+func TestUnifiedOrderTypedFail(t *testing.T) {
+	c := newTestAPIClient(t, "/pay/unifiedorder", successResponse().SetString("result_code", FAIL).SetString("err_code", "ORDERPAID"), nil)
+	result, err := c.UnifiedOrderTyped(orderParams().SetString("trade_type", TradeTypeNative))
+	if _, ok := err.(*WxError); !ok {
+		t.Errorf("err = %v, want *WxError", err)
+	}
+	if result == nil || result.ErrCode != "ORDERPAID" {
+		t.Errorf("result = %+v, want the parsed failure", result)
+	}
+}
+
+func TestOrderQueryTyped(t *testing.T) {
+	res := successResponse().SetString("trade_state", TradeStateSuccess).SetString("total_fee", "88").
+		SetString("cash_fee", "80").SetString("transaction_id", "1008450740201411110005820873").
+		SetString("out_trade_no", "1217752501201407033233368018").SetString("time_end", "20141030133525")
+	c := newTestAPIClient(t, "/pay/orderquery", res, nil)
+	result, err := c.OrderQueryTyped(Map{"out_trade_no": "1217752501201407033233368018"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalFee != 88 || result.CashFee != 80 || result.TransactionID != "1008450740201411110005820873" || result.TimeEnd != "20141030133525" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !result.IsPaid() || !result.IsFinal() || result.IsClosed() || result.IsRefunded() {
+		t.Errorf("helpers disagree with trade_state %s", result.TradeState)
+	}
+}
+
+func TestTradeStateHelpers(t *testing.T) {
+	tests := []struct {
+		state                           string
+		paid, closed, refunded, isFinal bool
+	}{
+		{TradeStateSuccess, true, false, false, true},
+		{TradeStateRefund, false, false, true, true},
+		{TradeStateClosed, false, true, false, true},
+		{TradeStateRevoked, false, false, false, true},
+		{TradeStatePayError, false, false, false, true},
+		{TradeStateNotPay, false, false, false, false},
+		{TradeStateUserPaying, false, false, false, false},
+	}
+	for _, tt := range tests {
+		r := &OrderQueryResult{TradeState: tt.state}
+		if r.IsPaid() != tt.paid || r.IsClosed() != tt.closed || r.IsRefunded() != tt.refunded || r.IsFinal() != tt.isFinal {
+			t.Errorf("%s: IsPaid=%t IsClosed=%t IsRefunded=%t IsFinal=%t", tt.state, r.IsPaid(), r.IsClosed(), r.IsRefunded(), r.IsFinal())
+		}
+		if IsFinalTradeState(tt.state) != tt.isFinal {
+			t.Errorf("IsFinalTradeState(%s) = %t", tt.state, !tt.isFinal)
+		}
+	}
+}
+
+func TestOrderQueryTypedTradeStateDesc(t *testing.T) {
+	res := successResponse().SetString("trade_state", TradeStatePayError).SetString("trade_type", TradeTypeMicroPay).
+		SetString("trade_state_desc", "支付失败，请重新下单支付")
+	c := newTestAPIClient(t, "/pay/orderquery", res, nil)
+	result, err := c.OrderQueryTyped(Map{"out_trade_no": "1217752501201407033233368018"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TradeState != TradeStatePayError || result.TradeType != TradeTypeMicroPay || result.TradeStateDesc != "支付失败，请重新下单支付" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !result.IsFinal() || result.IsPaid() {
+		t.Errorf("PAYERROR: IsFinal=%t IsPaid=%t", result.IsFinal(), result.IsPaid())
+	}
+}
+
+func TestTradeStateUnknown(t *testing.T) {
+	// 未知的交易状态不是终态, 调用方应继续查询
+	if IsFinalTradeState("") || IsFinalTradeState("success") || IsFinalTradeState("ACCEPT") {
+		t.Error("unknown trade_state reported as final")
+	}
+}
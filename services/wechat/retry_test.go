@@ -0,0 +1,65 @@
+package wechat
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+// 前failures次请求返回status, 之后返回成功的响应
+func newTestFlakyClient(t *testing.T, failures int32, status int, calls *int32) *Client {
+	t.Helper()
+	return newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		writeSigned(w, successResponse(), MD5)
+	})
+}
+
+func TestRetryServerError(t *testing.T) {
+	var calls int32
+	c := newTestFlakyClient(t, 2, http.StatusBadGateway, &calls)
+	c.SetMaxRetries(2)
+	if _, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("got %d calls, want 3", calls)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	var calls int32
+	c := newTestFlakyClient(t, 5, http.StatusServiceUnavailable, &calls)
+	c.SetMaxRetries(1)
+	_, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"})
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("err = %v, want 503 HTTPStatusError", err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want 2", calls)
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	var calls int32
+	c := newTestFlakyClient(t, 5, http.StatusBadRequest, &calls)
+	c.SetMaxRetries(3)
+	if _, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"}); err == nil {
+		t.Fatal("want error for 400")
+	}
+	if calls != 1 {
+		t.Errorf("client error retried: %d calls", calls)
+	}
+	// 默认不重试
+	calls = 0
+	c = newTestFlakyClient(t, 5, http.StatusBadGateway, &calls)
+	c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"})
+	if calls != 1 {
+		t.Errorf("retried by default: %d calls", calls)
+	}
+}
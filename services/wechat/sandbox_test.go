@@ -0,0 +1,82 @@
+package wechat
+
+import (
+	"net/http"
+	"testing"
+)
+
+const testSandboxKey = "6f8c2a1b9d0e4f3a7b5c8d2e1f0a9b3c"
+
+// 模拟沙箱环境: getsignkey返回沙箱签名秘钥, 其他api使用沙箱秘钥按请求的签名类型签名响应
+func newTestSandboxClient(t *testing.T, requests *[]Map) *Client {
+	t.Helper()
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		req := readRequest(t, r)
+		*requests = append(*requests, req)
+		if r.URL.Path == "/sandboxnew/pay/getsignkey" {
+			w.Write([]byte(Map{"return_code": SUCCESS, "return_msg": "ok", "sandbox_signkey": testSandboxKey}.ToXML()))
+			return
+		}
+		signType := req.GetString("sign_type")
+		if signType == "" {
+			signType = MD5
+		}
+		signer := NewClient(NewAccount(testAppID, testMchID, testSandboxKey, true))
+		res, _ := signer.setSignWith(successResponse(), signType)
+		w.Write([]byte(res.ToXML()))
+	})
+	c.account = NewAccount(testAppID, testMchID, testAPIKey, true)
+	return c
+}
+
+func TestUseSandboxSignKey(t *testing.T) {
+	var requests []Map
+	c := newTestSandboxClient(t, &requests)
+	if err := c.UseSandboxSignKey(); err != nil {
+		t.Fatal(err)
+	}
+	// 获取沙箱秘钥的请求使用正式的apiKey签名
+	if len(requests) != 1 || !NewClient(NewAccount(testAppID, testMchID, testAPIKey, true)).VerifySign(requests[0]) {
+		t.Fatalf("getsignkey request not signed with apiKey: %v", requests)
+	}
+	if _, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.VerifySign(requests[1]) || c.account.apiKey != testSandboxKey {
+		t.Error("request after UseSandboxSignKey not signed with the sandbox key")
+	}
+}
+
+func TestUseSandboxSignKeyNotSandbox(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("getsignkey called outside the sandbox")
+	})
+	if err := c.UseSandboxSignKey(); err != nil || c.account.apiKey != testAPIKey {
+		t.Errorf("err = %v, apiKey = %q", err, c.account.apiKey)
+	}
+}
+
+func TestUseSandboxSignKeyHMACSHA256(t *testing.T) {
+	var requests []Map
+	c := newTestSandboxClient(t, &requests)
+	if err := c.SetSignType(HMACSHA256); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UseSandboxSignKey(); err != nil {
+		t.Fatal(err)
+	}
+	// 获取沙箱秘钥的请求始终使用MD5签名
+	md5Client := NewClient(NewAccount(testAppID, testMchID, testAPIKey, true))
+	if requests[0].ContainsKey("sign_type") || !md5Client.VerifySign(requests[0]) {
+		t.Errorf("getsignkey request not MD5-signed: %v", requests[0])
+	}
+	if _, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Fatal(err)
+	}
+	if requests[1].GetString("sign_type") != HMACSHA256 || !c.VerifySign(requests[1]) {
+		t.Errorf("request not HMAC-signed with the sandbox key: %v", requests[1])
+	}
+	if c.signType != HMACSHA256 {
+		t.Errorf("signType changed to %s", c.signType)
+	}
+}
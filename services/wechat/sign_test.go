@@ -0,0 +1,176 @@
+package wechat
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// 生成测试用的RSA私钥
+func newTestRSAKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return key, keyPEM
+}
+
+func TestSignRSA(t *testing.T) {
+	key, keyPEM := newTestRSAKey(t)
+	account := NewAccount(testAppID, testMchID, testAPIKey, false)
+	if err := account.SetPrivateKey(keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithSignType(account, RSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := Map{"mch_id": testMchID, "nonce_str": "5K8264ILTKCH16CQ2502SI8ZNMTM67VS", "body": "test"}
+	sign := c.Sign(params)
+	sig, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// RSA签名原串不拼接apiKey
+	digest := sha256.Sum256([]byte("body=test&mch_id=10000100&nonce_str=5K8264ILTKCH16CQ2502SI8ZNMTM67VS"))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+}
+
+func TestSignRSAMissingKey(t *testing.T) {
+	account := NewAccount(testAppID, testMchID, testAPIKey, false)
+	if c, err := NewClientWithSignType(account, RSA); !errors.Is(err, ErrMissingKey) || c != nil {
+		t.Errorf("NewClientWithSignType(RSA) = %v, %v, want ErrMissingKey", c, err)
+	}
+	c := NewClient(account)
+	if err := c.SetSignType(RSA); !errors.Is(err, ErrMissingKey) || c.signType != MD5 {
+		t.Errorf("SetSignType(RSA) err = %v, signType = %s", err, c.signType)
+	}
+	if _, err := c.WithSignType(RSA); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("WithSignType(RSA) err = %v, want ErrMissingKey", err)
+	}
+	// 内部的签名方法仍然返回错误
+	if _, err := c.signWith(Map{"body": "test"}, RSA); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("signWith(RSA) err = %v, want ErrMissingKey", err)
+	}
+}
+
+func TestRSAClientVerifiesResponse(t *testing.T) {
+	_, keyPEM := newTestRSAKey(t)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// 微信使用apiKey对响应签名
+		writeSigned(w, successResponse(), MD5)
+	})
+	if err := c.account.SetPrivateKey(keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetSignType(RSA); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CloseOrder(Map{"out_trade_no": "1217752501201407033233368018"}); err != nil {
+		t.Errorf("CloseOrder: %v", err)
+	}
+}
+
+// 微信支付文档中的签名示例
+func docSignParams() Map {
+	return Map{"appid": "wxd930ea5d5a258f4f", "mch_id": "10000100", "device_info": "1000", "body": "test", "nonce_str": "ibuaiVcKdpRxkhJA"}
+}
+
+func TestSignSource(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	want := "appid=wxd930ea5d5a258f4f&body=test&device_info=1000&mch_id=10000100&nonce_str=ibuaiVcKdpRxkhJA&key=" + testAPIKey
+	if got := c.SignSource(docSignParams()); got != want {
+		t.Errorf("SignSource() = %q, want %q", got, want)
+	}
+	if got := c.SignSource(Map{}); got != "key="+testAPIKey {
+		t.Errorf("SignSource(empty) = %q", got)
+	}
+}
+
+func TestSignDocExample(t *testing.T) {
+	tests := []struct {
+		signType string
+		want     string
+	}{
+		{MD5, "9A0A8659F005D6984697E2CA0A9CF3B7"},
+		{HMACSHA256, "6A9AE1657590FD6257D693A078E1C3E4BB6BA4DC30B23E0EE2496E54170DACD6"},
+	}
+	for _, tt := range tests {
+		c, err := NewClientWithSignType(NewAccount(testAppID, testMchID, testAPIKey, false), tt.signType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Sign(docSignParams()); got != tt.want {
+			t.Errorf("%s: Sign() = %s, want %s", tt.signType, got, tt.want)
+		}
+	}
+}
+
+func TestSignIgnoresEmptyAndSignFields(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	want := c.Sign(docSignParams())
+	params := docSignParams().SetString("attach", "").SetString("detail", "  \n").
+		SetString("sign", "X").SetString("SIGN", "Y").SetString("Sign", "Z")
+	if got := c.Sign(params); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+	signed := c.SetSign(params)
+	if signed.ContainsKey("SIGN") || signed.ContainsKey("Sign") || signed.GetString("sign") != want {
+		t.Errorf("SetSign() = %v", signed)
+	}
+}
+
+func TestSignWithKeepsClientState(t *testing.T) {
+	c := newTestCertClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testBill))
+	})
+	if _, err := c.DownloadFundFlow(Map{"bill_date": "20141110", "account_type": "Basic"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.signType != MD5 {
+		t.Errorf("signType = %s after DownloadFundFlow, want MD5", c.signType)
+	}
+	params := docSignParams()
+	hmacSign, err := c.signWith(params, HMACSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md5Sign := c.Sign(params)
+	if hmacSign == md5Sign || len(hmacSign) != 64 || len(md5Sign) != 32 {
+		t.Errorf("signWith(HMAC-SHA256) = %s, Sign() = %s", hmacSign, md5Sign)
+	}
+	if _, err := c.signWith(params, "SHA1"); err != ErrInvalidSignType {
+		t.Errorf("signWith(SHA1) err = %v, want ErrInvalidSignType", err)
+	}
+}
+
+func TestSetSign(t *testing.T) {
+	for _, tt := range []struct {
+		signType, want string
+	}{
+		{MD5, "9A0A8659F005D6984697E2CA0A9CF3B7"},
+		{HMACSHA256, "6A9AE1657590FD6257D693A078E1C3E4BB6BA4DC30B23E0EE2496E54170DACD6"},
+	} {
+		c, _ := NewClientWithSignType(NewAccount(testAppID, testMchID, testAPIKey, false), tt.signType)
+		params := docSignParams().SetString("sign", "stale")
+		signed := c.SetSign(params)
+		if signed.GetString("sign") != tt.want || !c.VerifySign(signed) {
+			t.Errorf("%s: sign = %s, want %s", tt.signType, signed.GetString("sign"), tt.want)
+		}
+		// 签名保存在传入的Map中
+		if params.GetString("sign") != tt.want {
+			t.Errorf("%s: SetSign did not update params", tt.signType)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package wechat
+
+import "sync"
+
+// 并发安全的请求参数, 适用于多个goroutine同时设置参数的场景
+// 签名和发送请求前通过Snapshot取得Map副本
+type SyncMap struct {
+	mu sync.RWMutex
+	m  Map
+}
+
+// 创建并发安全的请求参数
+func NewSyncMap() *SyncMap {
+	return &SyncMap{m: make(Map)}
+}
+
+// 设置参数
+func (s *SyncMap) SetString(k, v string) *SyncMap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m.SetString(k, v)
+	return s
+}
+
+// 读取参数
+func (s *SyncMap) GetString(k string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.GetString(k)
+}
+
+// 删除参数
+func (s *SyncMap) Delete(k string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, k)
+}
+
+// 返回当前参数的副本, 之后对SyncMap的修改不影响副本
+func (s *SyncMap) Snapshot() Map {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.Clone()
+}
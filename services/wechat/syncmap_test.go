@@ -0,0 +1,44 @@
+package wechat
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// 使用go test -race运行, 并发设置参数的同时签名不应出现数据竞争
+func TestSyncMapConcurrentSign(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	params := NewSyncMap().SetString("body", "test")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				params.SetString("attach", strconv.Itoa(i*100+j))
+			}
+		}(i)
+	}
+	for i := 0; i < 100; i++ {
+		snapshot := params.Snapshot()
+		signed := c.SetSign(snapshot)
+		if !c.VerifySign(signed) {
+			t.Fatal("snapshot does not verify")
+		}
+	}
+	wg.Wait()
+}
+
+func TestSyncMapSnapshotIsolated(t *testing.T) {
+	params := NewSyncMap().SetString("body", "test")
+	snapshot := params.Snapshot()
+	params.SetString("body", "changed")
+	params.Delete("body")
+	if snapshot.GetString("body") != "test" {
+		t.Errorf("snapshot body = %q, want %q", snapshot.GetString("body"), "test")
+	}
+	if params.GetString("body") != "" {
+		t.Error("Delete did not remove the key")
+	}
+}
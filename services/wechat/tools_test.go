@@ -0,0 +1,75 @@
+package wechat
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	var req Map
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/payitil/report" {
+			http.NotFound(w, r)
+			return
+		}
+		req = readRequest(t, r)
+		// 交易保障接口的响应不带签名
+		w.Write([]byte(Map{"return_code": SUCCESS, "result_code": SUCCESS}.ToXML()))
+	})
+	params := Map{"interface_url": UnifiedOrderUrl, "execute_time": "1000", "return_code": SUCCESS,
+		"result_code": SUCCESS, "user_ip": "8.8.8.8", "time": "20091227091010"}
+	if _, err := c.Report(params); err != nil {
+		t.Fatal(err)
+	}
+	if req.GetString("interface_url") != UnifiedOrderUrl || !c.VerifySign(req) {
+		t.Errorf("unexpected request: %v", req)
+	}
+	delete(params, "execute_time")
+	if _, err := c.Report(params); err == nil {
+		t.Error("want error without execute_time")
+	}
+}
+
+func TestShortURL(t *testing.T) {
+	const longURL = "weixin://wxpay/bizpayurl?sign=XXXXX&appid=XXXXX&mch_id=XXXXX&product_id=XXXXXX&time_stamp=XXXXXX&nonce_str=XXXXX"
+	var req Map
+	c := newTestAPIClient(t, "/tools/shorturl", successResponse().SetString("short_url", "weixin://wxpay/s/XXXXXX"), &req)
+	shortURL, err := c.ShortURL(longURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shortURL != "weixin://wxpay/s/XXXXXX" {
+		t.Errorf("short_url = %q", shortURL)
+	}
+	// 签名使用原串, 传输时URL编码
+	if req.GetString("long_url") != url.QueryEscape(longURL) {
+		t.Errorf("long_url = %q, want it URL-encoded", req.GetString("long_url"))
+	}
+	if !c.VerifySign(req.Clone().SetString("long_url", longURL)) {
+		t.Error("sign not computed over the raw long_url")
+	}
+}
+
+func TestAuthCodeToOpenID(t *testing.T) {
+	var req Map
+	c := newTestAPIClient(t, "/tools/authcodetoopenid", successResponse().SetString("openid", "oUpF8uN95-Ptaags6E_roPHg7AG0"), &req)
+	openID, err := c.AuthCodeToOpenID("120061098828009406")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if openID != "oUpF8uN95-Ptaags6E_roPHg7AG0" {
+		t.Errorf("openid = %q", openID)
+	}
+	if req.GetString("auth_code") != "120061098828009406" || !c.VerifySign(req) {
+		t.Errorf("unexpected request: %v", req)
+	}
+}
+
+func TestAuthCodeToOpenIDError(t *testing.T) {
+	c := newTestAPIClient(t, "/tools/authcodetoopenid", successResponse().SetString("result_code", FAIL).SetString("err_code", "AUTHCODEEXPIRE"), nil)
+	_, err := c.AuthCodeToOpenID("120061098828009406")
+	if wxErr, ok := err.(*WxError); !ok || !wxErr.IsErrCode("AUTHCODEEXPIRE") {
+		t.Errorf("err = %v, want AUTHCODEEXPIRE", err)
+	}
+}
@@ -0,0 +1,147 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// 企业付款的状态, 对应查询结果中的status字段
+const (
+	TransferStatusSuccess    = "SUCCESS"    // 转账成功
+	TransferStatusFailed     = "FAILED"     // 转账失败
+	TransferStatusProcessing = "PROCESSING" // 处理中
+)
+
+// 企业付款到零钱(需要商户证书)
+// 该接口使用mch_appid和mchid作为商户参数名
+func (c *Client) Transfer(params Map) (Map, error) {
+	err := requireParams(params, "partner_trade_no", "openid", "check_name", "amount", "desc")
+	if err != nil {
+		return nil, err
+	}
+	h, err := c.certHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	params = params.Clone().SetString("mch_appid", c.account.appID).
+		SetString("mchid", c.account.mchID).
+		SetString("nonce_str", c.nonce())
+	// 企业付款相关接口只支持MD5签名
+	params, err = c.setSignWith(params, MD5)
+	if err != nil {
+		return nil, err
+	}
+	// 发送企业付款请求
+	return c.doPostXMLUnsigned(context.Background(), h, c.apiURL(TransferUrl, TransferUrl), params)
+}
+
+// 查询企业付款(需要商户证书)
+// 通过结果中的status字段判断转账状态, 参见TransferStatusSuccess等常量
+func (c *Client) TransferQuery(params Map) (Map, error) {
+	if err := requireParams(params, "partner_trade_no"); err != nil {
+		return nil, err
+	}
+	h, err := c.certHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	params = params.Clone().SetString("appid", c.account.appID).
+		SetString("mch_id", c.account.mchID).
+		SetString("nonce_str", c.nonce())
+	// 企业付款相关接口只支持MD5签名
+	params, err = c.setSignWith(params, MD5)
+	if err != nil {
+		return nil, err
+	}
+	// 发送查询企业付款请求
+	return c.doPostXMLUnsigned(context.Background(), h, c.apiURL(TransferQueryUrl, TransferQueryUrl), params)
+}
+
+// 发放普通红包(需要商户证书)
+// 该接口使用wxappid和mch_id作为商户参数名, 只支持MD5签名
+func (c *Client) SendRedPack(params Map) (Map, error) {
+	err := requireParams(params, "mch_billno", "send_name", "re_openid", "total_amount", "total_num", "wishing", "client_ip", "act_name", "remark")
+	if err != nil {
+		return nil, err
+	}
+	h, err := c.certHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	params = params.Clone().SetString("wxappid", c.account.appID).
+		SetString("mch_id", c.account.mchID).
+		SetString("nonce_str", c.nonce())
+	params, err = c.setSignWith(params, MD5)
+	if err != nil {
+		return nil, err
+	}
+	// 发送发放红包请求
+	return c.doPostXMLUnsigned(context.Background(), h, c.apiURL(SendRedPackUrl, SendRedPackUrl), params)
+}
+
+// 查询红包记录(需要商户证书)
+// 红包状态在status字段中, 领取列表hblist中的第n条记录展开为openid_n、amount_n、rcv_time_n, 记录数为hblist_count
+func (c *Client) RedPackQuery(params Map) (Map, error) {
+	if err := requireParams(params, "mch_billno"); err != nil {
+		return nil, err
+	}
+	h, err := c.certHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	params = params.Clone().SetString("appid", c.account.appID).
+		SetString("mch_id", c.account.mchID).
+		SetString("bill_type", "MCHT").
+		SetString("nonce_str", c.nonce())
+	params, err = c.setSignWith(params, MD5)
+	if err != nil {
+		return nil, err
+	}
+	// 发送查询红包记录请求
+	raw, err := c.doPost(context.Background(), h, c.apiURL(RedPackQueryUrl, RedPackQueryUrl), params)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.parseResponse(raw, unsignedResponse)
+	if res != nil {
+		expandHBList(raw, res)
+	}
+	return res, err
+}
+
+// 将hblist中的每条hbinfo记录以_n为后缀展开到m中
+func expandHBList(raw []byte, m Map) {
+	decoder := newXMLDecoder(bytes.NewReader(raw))
+	var (
+		count   int    // 已读取的hbinfo数量
+		inInfo  bool   // 是否在hbinfo元素中
+		field   string // 当前的字段名
+		content string // 当前字段的内容
+	)
+	for t, err := decoder.Token(); err == nil; t, err = decoder.Token() {
+		switch token := t.(type) {
+		case xml.StartElement:
+			if token.Name.Local == "hbinfo" {
+				inInfo = true
+			} else if inInfo {
+				field, content = token.Name.Local, ""
+			}
+		case xml.CharData:
+			if inInfo && len(field) > 0 {
+				content += string(token)
+			}
+		case xml.EndElement:
+			if token.Name.Local == "hbinfo" {
+				inInfo = false
+				count++
+			} else if inInfo && token.Name.Local == field {
+				m.SetString(field+"_"+strconv.Itoa(count), strings.TrimSpace(content))
+				field = ""
+			}
+		}
+	}
+	m.SetInt64("hblist_count", int64(count))
+}
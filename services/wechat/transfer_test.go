@@ -0,0 +1,146 @@
+package wechat
+
+import (
+	"net/http"
+	"testing"
+)
+
+func transferParams() Map {
+	return Map{"partner_trade_no": "10000098201411111234567890", "openid": "oxTWIuGaIt6gTKsQRLau2M0yL16E",
+		"check_name": "NO_CHECK", "amount": "100", "desc": "理赔"}
+}
+
+// 创建带商户证书的测试客户端, 只响应path, 响应不带签名
+func newTestTransferClient(t *testing.T, path string, res Map, req *Map) *Client {
+	t.Helper()
+	c := newTestCertClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		*req = readRequest(t, r)
+		w.Write([]byte(res.ToXML()))
+	})
+	// 企业付款相关接口只支持MD5签名, 与客户端的签名类型无关
+	c.SetSignType(HMACSHA256)
+	return c
+}
+
+func TestTransfer(t *testing.T) {
+	var req Map
+	res := Map{"return_code": SUCCESS, "result_code": SUCCESS, "payment_no": "1000018301201505190181489473"}
+	c := newTestTransferClient(t, "/mmpaymkttransfers/promotion/transfers", res, &req)
+	got, err := c.Transfer(transferParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetString("payment_no") != "1000018301201505190181489473" {
+		t.Errorf("unexpected response: %v", got)
+	}
+	if req.GetString("mch_appid") != testAppID || req.GetString("mchid") != testMchID || req.ContainsKey("appid") {
+		t.Errorf("unexpected merchant fields: %v", req)
+	}
+	if req.ContainsKey("sign_type") || !c.verifySignWith(req, MD5) {
+		t.Errorf("request not signed with MD5: %v", req)
+	}
+	if _, err := c.Transfer(transferParams().SetString("amount", "")); err == nil {
+		t.Error("want error without amount")
+	}
+}
+
+func TestTransferMissingCert(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	if _, err := c.Transfer(transferParams()); err != ErrMissingCert {
+		t.Errorf("err = %v, want ErrMissingCert", err)
+	}
+}
+
+func TestTransferQuery(t *testing.T) {
+	var req Map
+	res := Map{"return_code": SUCCESS, "result_code": SUCCESS, "status": TransferStatusSuccess, "payment_amount": "100"}
+	c := newTestTransferClient(t, "/mmpaymkttransfers/gettransferinfo", res, &req)
+	got, err := c.TransferQuery(Map{"partner_trade_no": "10000098201411111234567890"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetString("status") != TransferStatusSuccess {
+		t.Errorf("status = %q", got.GetString("status"))
+	}
+	if req.GetString("appid") != testAppID || req.GetString("mch_id") != testMchID || !c.verifySignWith(req, MD5) {
+		t.Errorf("unexpected request: %v", req)
+	}
+	if _, err := c.TransferQuery(Map{}); err == nil {
+		t.Error("want error without partner_trade_no")
+	}
+}
+
+func redPackParams() Map {
+	return Map{"mch_billno": "10000098201411111234567890", "send_name": "天虹百货", "re_openid": "oxTWIuGaIt6gTKsQRLau2M0yL16E",
+		"total_amount": "1000", "total_num": "1", "wishing": "感谢您参加猜灯谜活动", "client_ip": "192.168.0.1",
+		"act_name": "猜灯谜抢红包活动", "remark": "猜越多得越多"}
+}
+
+func TestSendRedPack(t *testing.T) {
+	var req Map
+	res := Map{"return_code": SUCCESS, "result_code": SUCCESS, "send_listid": "100000000020150520314766074200"}
+	c := newTestTransferClient(t, "/mmpaymkttransfers/sendredpack", res, &req)
+	params := redPackParams()
+	got, err := c.SendRedPack(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetString("send_listid") != "100000000020150520314766074200" {
+		t.Errorf("unexpected response: %v", got)
+	}
+	if req.GetString("wxappid") != testAppID || req.GetString("mch_id") != testMchID || req.ContainsKey("appid") {
+		t.Errorf("unexpected merchant fields: %v", req)
+	}
+	if req.ContainsKey("sign_type") || !c.verifySignWith(req, MD5) {
+		t.Errorf("request not signed with MD5: %v", req)
+	}
+	if params.ContainsKey("wxappid") || params.ContainsKey("sign") {
+		t.Errorf("caller params modified: %v", params)
+	}
+	if _, err := c.SendRedPack(redPackParams().SetString("wishing", "")); err == nil {
+		t.Error("want error without wishing")
+	}
+	noCert := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	if _, err := noCert.SendRedPack(redPackParams()); err != ErrMissingCert {
+		t.Errorf("err = %v, want ErrMissingCert", err)
+	}
+}
+
+func TestRedPackQuery(t *testing.T) {
+	var req Map
+	c := newTestCertClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mmpaymkttransfers/gethbinfo" {
+			http.NotFound(w, r)
+			return
+		}
+		req = readRequest(t, r)
+		w.Write([]byte(`<xml><return_code><![CDATA[SUCCESS]]></return_code><result_code><![CDATA[SUCCESS]]></result_code>` +
+			`<mch_billno><![CDATA[10000098201411111234567890]]></mch_billno><status><![CDATA[RECEIVED]]></status>` +
+			`<hblist><hbinfo><openid><![CDATA[oxTWIuGaIt6gTKsQRLau2M0yL16E]]></openid><amount>100</amount><rcv_time><![CDATA[2015-04-21 20:00:00]]></rcv_time></hbinfo>` +
+			`<hbinfo><openid><![CDATA[ohO4GtzOAAYMp2yapORH3dQB3W18]]></openid><amount>200</amount><rcv_time><![CDATA[2015-04-21 20:01:00]]></rcv_time></hbinfo></hblist></xml>`))
+	})
+	got, err := c.RedPackQuery(Map{"mch_billno": "10000098201411111234567890"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Map{
+		"status": "RECEIVED", "hblist_count": "2",
+		"openid_0": "oxTWIuGaIt6gTKsQRLau2M0yL16E", "amount_0": "100", "rcv_time_0": "2015-04-21 20:00:00",
+		"openid_1": "ohO4GtzOAAYMp2yapORH3dQB3W18", "amount_1": "200", "rcv_time_1": "2015-04-21 20:01:00",
+	}
+	for k, v := range want {
+		if got.GetString(k) != v {
+			t.Errorf("%s = %q, want %q", k, got.GetString(k), v)
+		}
+	}
+	if req.GetString("bill_type") != "MCHT" || req.GetString("appid") != testAppID || !c.verifySignWith(req, MD5) {
+		t.Errorf("unexpected request: %v", req)
+	}
+	if _, err := c.RedPackQuery(Map{}); err == nil {
+		t.Error("want error without mch_billno")
+	}
+}
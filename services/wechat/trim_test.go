@@ -0,0 +1,39 @@
+package wechat
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSetSignTrimsValues(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	params := c.SetSign(Map{"body": " 腾讯充值中心-QQ会员充值\n", "out_trade_no": "1217752501201407033233368018"})
+	if params.GetString("body") != "腾讯充值中心-QQ会员充值" {
+		t.Errorf("body = %q, want trimmed", params.GetString("body"))
+	}
+	if !c.VerifySign(params) {
+		t.Error("trimmed params do not verify")
+	}
+}
+
+func TestTransferSendsSignedValues(t *testing.T) {
+	var req Map
+	c := newTestCertClient(t, func(w http.ResponseWriter, r *http.Request) {
+		req = readRequest(t, r)
+		w.Write([]byte(successResponse().ToXML()))
+	})
+	params := Map{"partner_trade_no": "10000098201411111234567890", "openid": "oxTWIuGaIt6gTKsQRLau2M0yL16E",
+		"check_name": "NO_CHECK", "amount": "100", "desc": "payout "}
+	if _, err := c.Transfer(params); err != nil {
+		t.Fatal(err)
+	}
+	if req.GetString("desc") != "payout" {
+		t.Errorf("desc = %q, want %q", req.GetString("desc"), "payout")
+	}
+	if !c.VerifySign(req) {
+		t.Error("transmitted request does not match its sign")
+	}
+	if params.GetString("desc") != "payout " {
+		t.Error("caller's params modified")
+	}
+}
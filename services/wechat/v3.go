@@ -0,0 +1,236 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	V3AuthSchema           = "WECHATPAY2-SHA256-RSA2048"   // v3接口的认证类型
+	V3NativeTransactionUrl = "/v3/pay/transactions/native" // Native下单api(v3)
+	v3BodyType             = "application/json; charset=utf-8"
+)
+
+// 微信支付v3接口客户端, 使用JSON报文和SHA256-RSA2048签名
+type V3Client struct {
+	appID                string            // 公众账号ID
+	mchID                string            // 商户号
+	serialNo             string            // 商户证书序列号
+	privateKey           *rsa.PrivateKey   // 商户私钥
+	platformCert         *x509.Certificate // 微信支付平台证书, 用于验证响应签名
+	httpConnectTimeoutMs int               // 连接超时时间
+	httpReadTimeoutMs    int               // 读取超时时间
+	defaultClient        *http.Client      // 按超时设置创建的http客户端
+	httpClient           *http.Client      // 自定义的http客户端
+	baseURL              string            // api域名
+	nonceGenerator       func() string     // 随机字符串生成器
+}
+
+// v3接口返回的错误
+type V3Error struct {
+	StatusCode int    `json:"-"`       // http状态码
+	Code       string `json:"code"`    // 错误码
+	Message    string `json:"message"` // 错误描述
+}
+
+func (e *V3Error) Error() string {
+	return fmt.Sprintf("wechat: v3 status=%d code=%s message=%s", e.StatusCode, e.Code, e.Message)
+}
+
+// 创建v3接口客户端
+func NewV3Client(appID, mchID, serialNo string, privateKey *rsa.PrivateKey) *V3Client {
+	c := &V3Client{
+		appID:                appID,
+		mchID:                mchID,
+		serialNo:             serialNo,
+		privateKey:           privateKey,
+		httpConnectTimeoutMs: 2000,
+		httpReadTimeoutMs:    1000,
+		baseURL:              DefaultBaseURL,
+	}
+	c.resetHTTPClient()
+	return c
+}
+
+// 设置微信支付平台证书, 未设置时所有响应都按验签失败处理
+func (c *V3Client) SetPlatformCertificate(cert *x509.Certificate) {
+	c.platformCert = cert
+}
+
+// 设置连接超时时间(毫秒)
+func (c *V3Client) SetConnectTimeout(ms int) error {
+	if ms <= 0 {
+		return ErrInvalidTimeout
+	}
+	c.httpConnectTimeoutMs = ms
+	c.resetHTTPClient()
+	return nil
+}
+
+// 设置读取超时时间(毫秒)
+func (c *V3Client) SetReadTimeout(ms int) error {
+	if ms <= 0 {
+		return ErrInvalidTimeout
+	}
+	c.httpReadTimeoutMs = ms
+	c.resetHTTPClient()
+	return nil
+}
+
+// 按当前的超时设置重新创建http客户端, 并关闭旧客户端的空闲连接
+func (c *V3Client) resetHTTPClient() {
+	if c.defaultClient != nil {
+		c.defaultClient.CloseIdleConnections()
+	}
+	c.defaultClient = newTimeoutClient(c.httpConnectTimeoutMs, c.httpReadTimeoutMs)
+}
+
+// 设置自定义的http客户端, 设置后超时配置不再生效
+func (c *V3Client) SetHTTPClient(h *http.Client) {
+	c.httpClient = h
+}
+
+// 获取发送请求使用的http客户端
+func (c *V3Client) client() *http.Client {
+	if c.httpClient != nil {
+		return c.httpClient
+	}
+	return c.defaultClient
+}
+
+// 设置随机字符串生成器
+func (c *V3Client) SetNonceGenerator(f func() string) {
+	c.nonceGenerator = f
+}
+
+// 生成随机字符串, 默认使用crypto/rand
+func (c *V3Client) nonce() string {
+	if c.nonceGenerator != nil {
+		return c.nonceGenerator()
+	}
+	return nonceStr()
+}
+
+// 设置api域名
+func (c *V3Client) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// 构造签名串, 每行以\n结尾:
+//
+//	HTTP请求方法\nURL\n请求时间戳\n请求随机串\n请求报文主体\n
+func v3Message(method, url string, timestamp int64, nonce string, body []byte) string {
+	return method + "\n" + url + "\n" + strconv.FormatInt(timestamp, 10) + "\n" + nonce + "\n" + string(body) + "\n"
+}
+
+// 生成Authorization请求头
+func (c *V3Client) Authorization(method, url string, timestamp int64, nonce string, body []byte) (string, error) {
+	digest := sha256.Sum256([]byte(v3Message(method, url, timestamp, nonce, body)))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`%s mchid="%s",nonce_str="%s",signature="%s",timestamp="%d",serial_no="%s"`,
+		V3AuthSchema, c.mchID, nonce, base64.StdEncoding.EncodeToString(sig), timestamp, c.serialNo), nil
+}
+
+// 校验响应签名, 签名串为: 应答时间戳\n应答随机串\n应答报文主体\n
+// Wechatpay-Serial须与平台证书序列号一致
+func (c *V3Client) verifyResponse(header http.Header, body []byte) error {
+	if c.platformCert == nil {
+		return ErrMissingPlatformCert
+	}
+	serial := fmt.Sprintf("%X", c.platformCert.SerialNumber)
+	if got := header.Get("Wechatpay-Serial"); !strings.EqualFold(got, serial) {
+		return fmt.Errorf("wechat: response signed by platform certificate %q, want %q", got, serial)
+	}
+	publicKey, ok := c.platformCert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("wechat: platform certificate is not RSA")
+	}
+	sig, err := base64.StdEncoding.DecodeString(header.Get("Wechatpay-Signature"))
+	if err != nil {
+		return ErrInvalidSign
+	}
+	message := header.Get("Wechatpay-Timestamp") + "\n" + header.Get("Wechatpay-Nonce") + "\n" + string(body) + "\n"
+	digest := sha256.Sum256([]byte(message))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], sig); err != nil {
+		return ErrInvalidSign
+	}
+	return nil
+}
+
+// 发送JSON请求, 校验响应签名并解析结果到result
+func (c *V3Client) do(ctx context.Context, method, url string, payload interface{}, result interface{}) error {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+	auth, err := c.Authorization(method, url, time.Now().Unix(), c.nonce(), body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", auth)
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("Content-Type", v3BodyType)
+	response, err := c.client().Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		v3Err := &V3Error{StatusCode: response.StatusCode}
+		json.Unmarshal(data, v3Err)
+		return v3Err
+	}
+	if err := c.verifyResponse(response.Header, data); err != nil {
+		return err
+	}
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+// Native下单(v3), 返回二维码链接code_url
+// params中无需包含appid和mchid
+func (c *V3Client) NativeTransaction(ctx context.Context, params map[string]interface{}) (string, error) {
+	payload := make(map[string]interface{}, len(params)+2)
+	for k, v := range params {
+		payload[k] = v
+	}
+	payload["appid"] = c.appID
+	payload["mchid"] = c.mchID
+	var result struct {
+		CodeURL string `json:"code_url"`
+	}
+	if err := c.do(ctx, http.MethodPost, V3NativeTransactionUrl, payload, &result); err != nil {
+		return "", err
+	}
+	return result.CodeURL, nil
+}
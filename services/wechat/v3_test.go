@@ -0,0 +1,210 @@
+package wechat
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSerialNo = "5157F09EFDC096DE15EBE81A47057A72"
+
+// 生成测试用的微信支付平台证书和私钥
+func newTestPlatformCert(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, _ := newTestRSAKey(t)
+	template := &x509.Certificate{SerialNumber: big.NewInt(2)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, cert
+}
+
+// 使用平台私钥对应答签名后写入响应, 序列号与newTestPlatformCert生成的证书一致
+func writeV3Signed(w http.ResponseWriter, key *rsa.PrivateKey, body string) {
+	timestamp, nonce := "1554208460", "c5ac7061fccab6bf3e254dcf98995b8c"
+	digest := sha256.Sum256([]byte(timestamp + "\n" + nonce + "\n" + body + "\n"))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	w.Header().Set("Wechatpay-Timestamp", timestamp)
+	w.Header().Set("Wechatpay-Nonce", nonce)
+	w.Header().Set("Wechatpay-Serial", "2")
+	w.Header().Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString(sig))
+	w.Write([]byte(body))
+}
+
+func TestV3Message(t *testing.T) {
+	got := v3Message(http.MethodPost, "/v3/pay/transactions/native", 1554208460, "593BEC0C930BF1AFEB40B4A08C8FB242", []byte(`{"amount":{"total":1}}`))
+	want := "POST\n/v3/pay/transactions/native\n1554208460\n593BEC0C930BF1AFEB40B4A08C8FB242\n{\"amount\":{\"total\":1}}\n"
+	if got != want {
+		t.Errorf("v3Message() = %q, want %q", got, want)
+	}
+	if got := v3Message(http.MethodGet, "/v3/certificates", 1554208460, "abc", nil); got != "GET\n/v3/certificates\n1554208460\nabc\n\n" {
+		t.Errorf("v3Message(GET) = %q", got)
+	}
+}
+
+func TestV3Authorization(t *testing.T) {
+	key, _ := newTestRSAKey(t)
+	c := NewV3Client(testAppID, testMchID, testSerialNo, key)
+	body := []byte(`{"description":"test"}`)
+	auth, err := c.Authorization(http.MethodPost, V3NativeTransactionUrl, 1554208460, "593BEC0C930BF1AFEB40B4A08C8FB242", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pattern := regexp.MustCompile(`^WECHATPAY2-SHA256-RSA2048 mchid="10000100",nonce_str="593BEC0C930BF1AFEB40B4A08C8FB242",signature="([^"]+)",timestamp="1554208460",serial_no="` + testSerialNo + `"$`)
+	m := pattern.FindStringSubmatch(auth)
+	if m == nil {
+		t.Fatalf("Authorization = %q", auth)
+	}
+	sig, err := base64.StdEncoding.DecodeString(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(v3Message(http.MethodPost, V3NativeTransactionUrl, 1554208460, "593BEC0C930BF1AFEB40B4A08C8FB242", body)))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+func TestV3NativeTransaction(t *testing.T) {
+	key, _ := newTestRSAKey(t)
+	platformKey, platformCert := newTestPlatformCert(t)
+	var payload map[string]interface{}
+	srv := newTestV3Server(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != V3NativeTransactionUrl || r.Header.Get("Content-Type") != v3BodyType {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &payload)
+		writeV3Signed(w, platformKey, `{"code_url":"weixin://wxpay/bizpayurl/up?pr=NwY5Mz9"}`)
+	})
+	c := NewV3Client(testAppID, testMchID, testSerialNo, key)
+	c.SetBaseURL(srv)
+	c.SetPlatformCertificate(platformCert)
+	codeURL, err := c.NativeTransaction(context.Background(), map[string]interface{}{"description": "test", "out_trade_no": "1217752501201407033233368018"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codeURL != "weixin://wxpay/bizpayurl/up?pr=NwY5Mz9" {
+		t.Errorf("code_url = %q", codeURL)
+	}
+	if payload["appid"] != testAppID || payload["mchid"] != testMchID || payload["description"] != "test" {
+		t.Errorf("unexpected payload: %v", payload)
+	}
+}
+
+func TestV3InvalidResponseSign(t *testing.T) {
+	key, _ := newTestRSAKey(t)
+	_, platformCert := newTestPlatformCert(t)
+	otherKey, _ := newTestRSAKey(t)
+	srv := newTestV3Server(t, func(w http.ResponseWriter, r *http.Request) {
+		writeV3Signed(w, otherKey, `{"code_url":"weixin://wxpay/bizpayurl/up?pr=NwY5Mz9"}`)
+	})
+	c := NewV3Client(testAppID, testMchID, testSerialNo, key)
+	c.SetBaseURL(srv)
+	c.SetPlatformCertificate(platformCert)
+	if _, err := c.NativeTransaction(context.Background(), nil); err != ErrInvalidSign {
+		t.Errorf("err = %v, want ErrInvalidSign", err)
+	}
+}
+
+func TestV3Error(t *testing.T) {
+	key, _ := newTestRSAKey(t)
+	srv := newTestV3Server(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"PARAM_ERROR","message":"参数错误"}`))
+	})
+	c := NewV3Client(testAppID, testMchID, testSerialNo, key)
+	c.SetBaseURL(srv)
+	_, err := c.NativeTransaction(context.Background(), nil)
+	var v3Err *V3Error
+	if !errors.As(err, &v3Err) || v3Err.StatusCode != http.StatusBadRequest || v3Err.Code != "PARAM_ERROR" {
+		t.Errorf("err = %v, want PARAM_ERROR V3Error", err)
+	}
+}
+
+func TestV3MissingPlatformCert(t *testing.T) {
+	key, _ := newTestRSAKey(t)
+	platformKey, _ := newTestPlatformCert(t)
+	srv := newTestV3Server(t, func(w http.ResponseWriter, r *http.Request) {
+		writeV3Signed(w, platformKey, `{"code_url":"weixin://wxpay/bizpayurl/up?pr=NwY5Mz9"}`)
+	})
+	c := NewV3Client(testAppID, testMchID, testSerialNo, key)
+	c.SetBaseURL(srv)
+	if _, err := c.NativeTransaction(context.Background(), nil); err != ErrMissingPlatformCert {
+		t.Errorf("err = %v, want ErrMissingPlatformCert", err)
+	}
+}
+
+func TestV3PlatformSerialMismatch(t *testing.T) {
+	key, _ := newTestRSAKey(t)
+	platformKey, platformCert := newTestPlatformCert(t)
+	srv := newTestV3Server(t, func(w http.ResponseWriter, r *http.Request) {
+		writeV3Signed(w, platformKey, `{"code_url":"weixin://wxpay/bizpayurl/up?pr=NwY5Mz9"}`)
+	})
+	// 签名正确, 但应答来自另一张序列号的平台证书
+	platformCert.SerialNumber = big.NewInt(3)
+	c := NewV3Client(testAppID, testMchID, testSerialNo, key)
+	c.SetBaseURL(srv)
+	c.SetPlatformCertificate(platformCert)
+	_, err := c.NativeTransaction(context.Background(), nil)
+	if err == nil || !strings.Contains(err.Error(), "platform certificate") {
+		t.Errorf("err = %v, want a serial mismatch error", err)
+	}
+}
+
+func TestV3ReadTimeout(t *testing.T) {
+	key, _ := newTestRSAKey(t)
+	done := make(chan struct{})
+	defer close(done)
+	srv := newTestV3Server(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-done:
+		}
+	})
+	c := NewV3Client(testAppID, testMchID, testSerialNo, key)
+	c.SetBaseURL(srv)
+	if err := c.SetReadTimeout(50); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err := c.NativeTransaction(context.Background(), nil)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("err = %v, want a timeout error", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("request took %v, want it to stop at the read timeout", d)
+	}
+}
+
+func TestV3InvalidTimeout(t *testing.T) {
+	key, _ := newTestRSAKey(t)
+	c := NewV3Client(testAppID, testMchID, testSerialNo, key)
+	if err := c.SetConnectTimeout(0); err != ErrInvalidTimeout {
+		t.Errorf("SetConnectTimeout(0) = %v, want ErrInvalidTimeout", err)
+	}
+	if err := c.SetReadTimeout(-1); err != ErrInvalidTimeout {
+		t.Errorf("SetReadTimeout(-1) = %v, want ErrInvalidTimeout", err)
+	}
+}
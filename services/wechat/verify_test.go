@@ -0,0 +1,105 @@
+package wechat
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestVerifySign(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	res := c.SetSign(successResponse().SetString("trade_state", TradeStateSuccess))
+	if !c.VerifySign(res) {
+		t.Fatal("valid response rejected")
+	}
+	wrong := res.Clone().SetString("sign", "9A0A8659F005D6984697E2CA0A9CF3B7")
+	if c.VerifySign(wrong) {
+		t.Error("wrong sign accepted")
+	}
+	tampered := res.Clone().SetString("trade_state", TradeStateNotPay)
+	if c.VerifySign(tampered) {
+		t.Error("tampered response accepted")
+	}
+}
+
+func TestOrderQueryResponseSign(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(w http.ResponseWriter)
+		wantErr error
+	}{
+		{"signed", func(w http.ResponseWriter) {
+			writeSigned(w, successResponse().SetString("trade_state", TradeStateSuccess), MD5)
+		}, nil},
+		{"tampered", func(w http.ResponseWriter) {
+			signer := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+			res := signer.SetSign(successResponse().SetString("trade_state", TradeStateNotPay))
+			w.Write([]byte(res.SetString("trade_state", TradeStateSuccess).ToXML()))
+		}, ErrInvalidSign},
+		{"missing sign", func(w http.ResponseWriter) {
+			w.Write([]byte(successResponse().SetString("trade_state", TradeStateSuccess).ToXML()))
+		}, ErrInvalidSign},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) { tt.write(w) })
+			_, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReturnCodeFailUnsigned(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// 通信失败的响应不带签名
+		w.Write([]byte(Map{"return_code": FAIL, "return_msg": "签名失败"}.ToXML()))
+	})
+	_, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"})
+	var wxErr *WxError
+	if !errors.As(err, &wxErr) || wxErr.ReturnMsg != "签名失败" {
+		t.Errorf("err = %v, want *WxError", err)
+	}
+}
+
+func TestVerifySignNewField(t *testing.T) {
+	for _, signType := range []string{MD5, HMACSHA256} {
+		c, err := NewClientWithSignType(NewAccount(testAppID, testMchID, testAPIKey, false), signType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 微信新增并参与签名的字段
+		res := c.SetSign(successResponse().SetString("sign_type", signType).SetString("promotion_detail_v9", "novel"))
+		if !c.VerifySign(res) {
+			t.Errorf("%s: signed novel field rejected", signType)
+		}
+		// 空值不参与签名
+		if !c.VerifySign(res.Clone().SetString("empty_field", "")) {
+			t.Errorf("%s: empty extra field rejected", signType)
+		}
+		// 签名后添加的字段会改变签名原串, 无法通过校验
+		if c.VerifySign(res.Clone().SetString("unsigned_field", "x")) {
+			t.Errorf("%s: unsigned extra field accepted", signType)
+		}
+	}
+}
+
+func TestVerifySignUsesResponseSignType(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	hmacClient, err := NewClientWithSignType(NewAccount(testAppID, testMchID, testAPIKey, false), HMACSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := hmacClient.SetSign(successResponse().SetString("sign_type", HMACSHA256))
+	if !c.VerifySign(res) {
+		t.Error("MD5 client rejected a response signed with its own sign_type HMAC-SHA256")
+	}
+	// 没有sign_type时使用客户端的签名类型
+	withoutType := res.Clone()
+	delete(withoutType, "sign_type")
+	withoutType = hmacClient.SetSign(withoutType)
+	if c.VerifySign(withoutType) || !hmacClient.VerifySign(withoutType) {
+		t.Error("response without sign_type should be verified with the client's sign type")
+	}
+}
@@ -0,0 +1,52 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitForPayment(t *testing.T) {
+	var n int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		state := TradeStateNotPay
+		if atomic.AddInt32(&n, 1) > 2 {
+			state = TradeStateSuccess
+		}
+		writeSigned(w, successResponse().SetString("trade_state", state), MD5)
+	})
+	res, err := c.WaitForPayment(context.Background(), Map{"out_trade_no": "1217752501201407033233368018"}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetString("trade_state") != TradeStateSuccess {
+		t.Errorf("trade_state = %q, want SUCCESS", res.GetString("trade_state"))
+	}
+	if n != 3 {
+		t.Errorf("queried %d times, want 3", n)
+	}
+}
+
+func TestWaitForPaymentCancel(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeSigned(w, successResponse().SetString("trade_state", TradeStateUserPaying), MD5)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.WaitForPayment(ctx, Map{"out_trade_no": "1217752501201407033233368018"}, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestWaitForPaymentInvalidInterval(t *testing.T) {
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := c.WaitForPayment(context.Background(), Map{"out_trade_no": "1"}, interval); err != ErrInvalidInterval {
+			t.Errorf("interval %v: err = %v, want ErrInvalidInterval", interval, err)
+		}
+	}
+}
@@ -2,30 +2,97 @@ package wechat
 
 import (
 	"bytes"
+	"context"
+	"crypto"
 	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/pem"
 	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/crypto/pkcs12"
+	"golang.org/x/text/encoding/simplifiedchinese"
 )
 
 const (
-	MD5                    = "MD5" // 默认加密方式
-	HMACSHA256             = "HMAC-SHA256"
-	SUCCESS                = "SUCCESS"
-	bodyType               = "application/xml; charset=utf-8"
-	SandboxGetSignKeyUrl   = "https://api.mch.weixin.qq.com/sandboxnew/pay/getsignkey"   // 获取沙箱签名秘钥api
-	SandboxUnifiedOrderUrl = "https://api.mch.weixin.qq.com/sandboxnew/pay/unifiedorder" // 统一下单api(沙箱)
-	SandboxOrderQueryUrl   = "https://api.mch.weixin.qq.com/sandboxnew/pay/orderquery"   // 查询订单api
-	UnifiedOrderUrl        = "https://api.mch.weixin.qq.com/pay/unifiedorder"            // 统一下单api
-	OrderQueryUrl          = "https://api.mch.weixin.qq.com/pay/orderquery"              // 查询订单api
+	DefaultBaseURL                 = "https://api.mch.weixin.qq.com"  // 默认api域名
+	BackupBaseURL                  = "https://api2.mch.weixin.qq.com" // 备用api域名
+	MD5                            = "MD5"                            // 默认加密方式
+	HMACSHA256                     = "HMAC-SHA256"
+	RSA                            = "RSA" // 使用商户私钥签名
+	SUCCESS                        = "SUCCESS"
+	FAIL                           = "FAIL"
+	bodyType                       = "application/xml; charset=utf-8"
+	xmlDeclaration                 = `<?xml version="1.0" encoding="UTF-8"?>`
+	retryBackoff                   = 100 * time.Millisecond                                                // 首次重试的等待时间, 之后每次翻倍
+	timeLayout                     = "20060102150405"                                                      // 微信支付的时间格式yyyyMMddHHmmss
+	SandboxGetSignKeyUrl           = "https://api.mch.weixin.qq.com/sandboxnew/pay/getsignkey"             // 获取沙箱签名秘钥api
+	SandboxUnifiedOrderUrl         = "https://api.mch.weixin.qq.com/sandboxnew/pay/unifiedorder"           // 统一下单api(沙箱)
+	SandboxOrderQueryUrl           = "https://api.mch.weixin.qq.com/sandboxnew/pay/orderquery"             // 查询订单api
+	UnifiedOrderUrl                = "https://api.mch.weixin.qq.com/pay/unifiedorder"                      // 统一下单api
+	OrderQueryUrl                  = "https://api.mch.weixin.qq.com/pay/orderquery"                        // 查询订单api
+	SandboxRefundUrl               = "https://api.mch.weixin.qq.com/sandboxnew/pay/refund"                 // 申请退款api(沙箱)
+	RefundUrl                      = "https://api.mch.weixin.qq.com/secapi/pay/refund"                     // 申请退款api
+	SandboxCloseOrderUrl           = "https://api.mch.weixin.qq.com/sandboxnew/pay/closeorder"             // 关闭订单api(沙箱)
+	CloseOrderUrl                  = "https://api.mch.weixin.qq.com/pay/closeorder"                        // 关闭订单api
+	SandboxRefundQueryUrl          = "https://api.mch.weixin.qq.com/sandboxnew/pay/refundquery"            // 查询退款api(沙箱)
+	RefundQueryUrl                 = "https://api.mch.weixin.qq.com/pay/refundquery"                       // 查询退款api
+	SandboxReverseUrl              = "https://api.mch.weixin.qq.com/sandboxnew/secapi/pay/reverse"         // 撤销订单api(沙箱)
+	ReverseUrl                     = "https://api.mch.weixin.qq.com/secapi/pay/reverse"                    // 撤销订单api
+	SandboxMicroPayUrl             = "https://api.mch.weixin.qq.com/sandboxnew/pay/micropay"               // 付款码支付api(沙箱)
+	MicroPayUrl                    = "https://api.mch.weixin.qq.com/pay/micropay"                          // 付款码支付api
+	TransferUrl                    = "https://api.mch.weixin.qq.com/mmpaymkttransfers/promotion/transfers" // 企业付款到零钱api
+	TransferQueryUrl               = "https://api.mch.weixin.qq.com/mmpaymkttransfers/gettransferinfo"     // 查询企业付款api
+	DownloadFundFlowUrl            = "https://api.mch.weixin.qq.com/pay/downloadfundflow"                  // 下载资金账单api
+	SandboxReportUrl               = "https://api.mch.weixin.qq.com/sandboxnew/payitil/report"             // 交易保障api(沙箱)
+	ReportUrl                      = "https://api.mch.weixin.qq.com/payitil/report"                        // 交易保障api
+	SandboxShortURLUrl             = "https://api.mch.weixin.qq.com/sandboxnew/tools/shorturl"             // 转换短链接api(沙箱)
+	ShortURLUrl                    = "https://api.mch.weixin.qq.com/tools/shorturl"                        // 转换短链接api
+	SandboxAuthCodeToOpenIDUrl     = "https://api.mch.weixin.qq.com/sandboxnew/tools/authcodetoopenid"     // 授权码查询openid api(沙箱)
+	AuthCodeToOpenIDUrl            = "https://api.mch.weixin.qq.com/tools/authcodetoopenid"                // 授权码查询openid api
+	ProfitSharingUrl               = "https://api.mch.weixin.qq.com/secapi/pay/profitsharing"              // 请求单次分账api
+	ProfitSharingAddReceiverUrl    = "https://api.mch.weixin.qq.com/pay/profitsharingaddreceiver"          // 添加分账接收方api
+	ProfitSharingRemoveReceiverUrl = "https://api.mch.weixin.qq.com/pay/profitsharingremovereceiver"       // 删除分账接收方api
+	SendRedPackUrl                 = "https://api.mch.weixin.qq.com/mmpaymkttransfers/sendredpack"         // 发放普通红包api
+	RedPackQueryUrl                = "https://api.mch.weixin.qq.com/mmpaymkttransfers/gethbinfo"           // 查询红包记录api
+	SandboxDownloadBillUrl         = "https://api.mch.weixin.qq.com/sandboxnew/pay/downloadbill"           // 下载对账单api(沙箱)
+	DownloadBillUrl                = "https://api.mch.weixin.qq.com/pay/downloadbill"                      // 下载对账单api
+
+)
+
+// 微信支付使用北京时间(UTC+8, 无夏令时)
+var beijing = time.FixedZone("CST", 8*60*60)
 
+var (
+	ErrInvalidTimeout      = errors.New("wechat: timeout must be positive")
+	ErrInvalidSign         = errors.New("wechat: invalid response sign")
+	ErrMissingCert         = errors.New("wechat: account has no certificate")
+	ErrInvalidSignType     = errors.New("wechat: unknown sign type")
+	ErrEmptyResponse       = errors.New("wechat: empty or malformed response")
+	ErrAttachTooLong       = errors.New("wechat: attach exceeds 127 bytes")
+	ErrMissingKey          = errors.New("wechat: account has no private key")
+	ErrInvalidInterval     = errors.New("wechat: poll interval must be positive")
+	ErrMissingPlatformCert = errors.New("wechat: v3 client has no platform certificate")
 )
 
 // =======================
@@ -52,21 +119,166 @@ func (p Map) GetInt64(k string) int64 {
 	return i
 }
 
+func (p Map) SetFloat64(k string, f float64, prec int) Map {
+	p[k] = strconv.FormatFloat(f, 'f', prec, 64)
+	return p
+}
+
+func (p Map) GetFloat64(k string) float64 {
+	f, _ := strconv.ParseFloat(p.GetString(k), 64)
+	return f
+}
+
+func (p Map) SetBool(k string, b bool) Map {
+	p[k] = strconv.FormatBool(b)
+	return p
+}
+
+func (p Map) GetBool(k string) bool {
+	b, _ := strconv.ParseBool(p.GetString(k))
+	return b
+}
+
+// 以base64(标准编码)保存二进制数据
+func (p Map) SetBytes(k string, b []byte) Map {
+	p[k] = base64.StdEncoding.EncodeToString(b)
+	return p
+}
+
+// 读取base64(标准编码)的二进制数据
+func (p Map) GetBytes(k string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(p.GetString(k))
+}
+
+// 附加数据attach的最大长度
+const maxAttachLen = 127
+
+// 设置附加数据attach, 支付结果通知中会原样返回, 长度不能超过127字节
+func (p Map) SetAttach(attach string) error {
+	if len(attach) > maxAttachLen {
+		return ErrAttachTooLong
+	}
+	p.SetString("attach", attach)
+	return nil
+}
+
+// 读取附加数据attach
+func (p Map) GetAttach() string {
+	return p.GetString("attach")
+}
+
+// 以base64保存二进制的附加数据, 编码后的长度不能超过127字节
+func (p Map) SetAttachBytes(b []byte) error {
+	return p.SetAttach(base64.StdEncoding.EncodeToString(b))
+}
+
+// 读取以base64保存的二进制附加数据
+func (p Map) GetAttachBytes() ([]byte, error) {
+	return p.GetBytes("attach")
+}
+
+// 设置订单的有效期, time_start为当前时间, time_expire为d之后
+// 时间格式为yyyyMMddHHmmss, 使用北京时间
+func (p Map) SetTimeExpire(d time.Duration) Map {
+	start := time.Now().In(beijing)
+	return p.SetString("time_start", start.Format(timeLayout)).
+		SetString("time_expire", start.Add(d).Format(timeLayout))
+}
+
+// 签名时由本包设置的字段, Canonicalize将这些字段的大小写变体统一为小写
+// 不包含appid, 因为前端支付参数使用appId
+var canonicalKeys = map[string]bool{
+	"mch_id":     true,
+	"nonce_str":  true,
+	"sign":       true,
+	"sign_type":  true,
+	"sub_appid":  true,
+	"sub_mch_id": true,
+}
+
+// 处理只有大小写不同的重复key(如sign和Sign), 避免重复的字段都参与签名
+// 重复的key保留原本就是小写的key, 否则保留字典序最小的key; mch_id、nonce_str、sign等本包设置的字段统一为小写
+// 其他key的大小写保持不变, 前端支付参数中的appId、nonceStr、timeStamp、paySign等驼峰字段不受影响
+func (p Map) Canonicalize() Map {
+	groups := make(map[string][]string)
+	for _, k := range p.sortedKeys() {
+		lower := strings.ToLower(k)
+		groups[lower] = append(groups[lower], k)
+	}
+	for lower, keys := range groups {
+		if len(keys) == 1 && !canonicalKeys[lower] {
+			continue
+		}
+		keep := keys[0]
+		if p.ContainsKey(lower) {
+			keep = lower
+		}
+		v := p[keep]
+		for _, k := range keys {
+			delete(p, k)
+		}
+		if canonicalKeys[lower] {
+			keep = lower
+		}
+		p[keep] = v
+	}
+	return p
+}
+
 // 判断key是否存在
 func (p Map) ContainsKey(key string) bool {
 	_, ok := p[key]
 	return ok
 }
 
-// 转换为xml字符串
+// 复制一份Map
+func (m Map) Clone() Map {
+	c := make(Map, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// 将other中的参数复制到m中, 相同的key以other为准
+func (m Map) Merge(other Map) Map {
+	for k, v := range other {
+		m[k] = v
+	}
+	return m
+}
+
+// 返回m与other合并后的新Map, 相同的key以other为准, 不修改m
+func (m Map) Merged(other Map) Map {
+	return m.Clone().Merge(other)
+}
+
+// 按字典序排列的key
+func (m Map) sortedKeys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 转换为带有xml声明的字符串
+func (m Map) ToXMLWithDeclaration() XML {
+	return XML(xmlDeclaration) + m.ToXML()
+}
+
+// 转换为xml字符串, 元素按key的字典序排列
 func (m Map) ToXML() XML {
 	var buf bytes.Buffer
 	buf.WriteString(`<xml>`)
-	for k, v := range m {
+	for _, k := range m.sortedKeys() {
+		v := m[k]
 		buf.WriteString(`<`)
 		buf.WriteString(k)
 		buf.WriteString(`><![CDATA[`)
-		buf.WriteString(v)
+		// CDATA中不能出现"]]>", 将其拆分到两个CDATA段中
+		buf.WriteString(strings.ReplaceAll(v, `]]>`, `]]]]><![CDATA[>`))
 		buf.WriteString(`]]></`)
 		buf.WriteString(k)
 		buf.WriteString(`>`)
@@ -80,28 +292,58 @@ func (m Map) ToXML() XML {
 
 type XML string
 
+// 创建xml解析器, 支持声明为GBK/GB2312/GB18030编码的文档
+func newXMLDecoder(r io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "utf-8", "utf8":
+			return input, nil
+		case "gbk", "gb2312":
+			return simplifiedchinese.GBK.NewDecoder().Reader(input), nil
+		case "gb18030":
+			return simplifiedchinese.GB18030.NewDecoder().Reader(input), nil
+		}
+		return nil, fmt.Errorf("wechat: unsupported charset %s", charset)
+	}
+	return decoder
+}
+
 // 转换为Map
+// 记录根元素下所有叶子元素的内容, 以元素名为key, 内容去除首尾空白
 func (x XML) ToMap() Map {
 	_map := make(Map)
 	xmlStr := string(x)
-	decoder := xml.NewDecoder(strings.NewReader(xmlStr))
+	decoder := newXMLDecoder(strings.NewReader(xmlStr))
 
-	var (
-		key   string
-		value string
-	)
+	// 未闭合的元素
+	type element struct {
+		name     string
+		value    string
+		hasChild bool
+	}
+	var stack []*element
 
 	for t, err := decoder.Token(); err == nil; t, err = decoder.Token() {
 		switch token := t.(type) {
 		case xml.StartElement: // 开始标签
-			key = token.Name.Local
+			if len(stack) > 0 {
+				stack[len(stack)-1].hasChild = true
+			}
+			stack = append(stack, &element{name: token.Name.Local})
 		case xml.CharData: // 标签内容
-			content := string([]byte(token))
-			value = content
-		}
-		if key != "xml" {
-			if value != "\n" {
-				_map.SetString(key, value)
+			if len(stack) > 0 {
+				stack[len(stack)-1].value += string(token)
+			}
+		case xml.EndElement: // 结束标签
+			if len(stack) == 0 {
+				continue
+			}
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			// 跳过根元素和非叶子元素
+			if len(stack) > 0 && !e.hasChild {
+				_map.SetString(e.name, strings.TrimSpace(e.value))
 			}
 		}
 	}
@@ -118,9 +360,77 @@ func (x XML) Compact() XML {
 	return XML(xmlStr)
 }
 
+// 添加缩进和换行, 便于阅读和打印日志
 func (x XML) String() string {
-	// TODO(添加缩进,换行)
-	return string(x)
+	var buf bytes.Buffer
+	decoder := newXMLDecoder(strings.NewReader(string(x)))
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	for {
+		t, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return string(x)
+		}
+		// 跳过元素之间的空白
+		if data, ok := t.(xml.CharData); ok && len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if err := encoder.EncodeToken(xml.CopyToken(t)); err != nil {
+			return string(x)
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		return string(x)
+	}
+	return buf.String()
+}
+
+// =======================
+
+// 微信支付返回的业务错误
+type WxError struct {
+	ReturnCode string // 通信标识
+	ReturnMsg  string // 通信错误信息
+	ResultCode string // 业务结果
+	ErrCode    string // 错误代码
+	ErrCodeDes string // 错误代码描述
+}
+
+func (e *WxError) Error() string {
+	if e.ReturnCode != SUCCESS {
+		return fmt.Sprintf("wechat: return_code=%s return_msg=%s", e.ReturnCode, e.ReturnMsg)
+	}
+	return fmt.Sprintf("wechat: result_code=%s err_code=%s err_code_des=%s", e.ResultCode, e.ErrCode, e.ErrCodeDes)
+}
+
+// 根据失败的响应创建错误
+func NewWxError(m Map) *WxError {
+	return &WxError{
+		ReturnCode: m.GetString("return_code"),
+		ReturnMsg:  m.GetString("return_msg"),
+		ResultCode: m.GetString("result_code"),
+		ErrCode:    m.GetString("err_code"),
+		ErrCodeDes: m.GetString("err_code_des"),
+	}
+}
+
+// 判断错误代码是否为code
+func (e *WxError) IsErrCode(code string) bool {
+	return e.ErrCode == code
+}
+
+// 检查响应中的通信标识与业务结果
+func checkResult(m Map) error {
+	if m.GetString("return_code") != SUCCESS {
+		return NewWxError(m)
+	}
+	if m.ContainsKey("result_code") && m.GetString("result_code") != SUCCESS {
+		return NewWxError(m)
+	}
+	return nil
 }
 
 // =======================
@@ -129,15 +439,62 @@ type Account struct {
 	appID     string
 	mchID     string
 	apiKey    string
-	certData  []byte
+	certData  []byte // p12格式的商户证书
+	certPEM   []byte // PEM格式的商户证书
+	keyPEM    []byte // PEM格式的商户私钥
 	isSandbox bool
+
+	privateKey *rsa.PrivateKey // RSA签名使用的商户私钥
+	subAppID   string          // 子商户公众账号ID(服务商模式)
+	subMchID   string          // 子商户号(服务商模式)
 }
 
 type Client struct {
-	account              *Account // 支付账号
-	signType             string   // 签名类型
-	httpConnectTimeoutMs int      // 连接超时时间
-	httpReadTimeoutMs    int      // 读取超时时间
+	lastLatency          int64         // 最近一次请求的耗时(纳秒), 放在首位以保证原子操作的对齐
+	account              *Account      // 支付账号
+	signType             string        // 签名类型
+	httpConnectTimeoutMs int           // 连接超时时间
+	httpReadTimeoutMs    int           // 读取超时时间
+	httpClient           *http.Client  // 自定义的http客户端
+	defaultClient        *http.Client  // 根据超时设置创建的http客户端, 在所有请求间复用连接
+	certClient           *certClient   // 携带商户证书的http客户端
+	baseURL              string        // api域名
+	fallbackHosts        []string      // 备用api域名, 主域名网络不通时依次尝试
+	logger               Logger        // 请求日志
+	maxRetries           int           // 最大重试次数
+	metrics              MetricsFunc   // 请求耗时回调
+	nonceGenerator       func() string // 随机字符串生成器
+	queryCache           *queryCache   // 查询订单结果的缓存
+	header               http.Header   // 附加到每个请求的请求头
+}
+
+// 请求耗时回调, endpoint为api路径, 如"/pay/unifiedorder"
+type MetricsFunc func(endpoint string, dur time.Duration, err error)
+
+// 请求日志接口, 每次调用api后以脱敏后的请求和响应调用Log
+type Logger interface {
+	Log(url string, req, resp XML, err error)
+}
+
+// 日志中需要隐藏的字段: 签名、沙箱签名密钥以及可用apiKey解密的退款通知内容
+var secretFields = []string{"sign", "paySign", "sandbox_signkey", "key", "req_info"}
+
+// 匹配各个需要隐藏的字段
+var secretPatterns = func() map[string]*regexp.Regexp {
+	patterns := make(map[string]*regexp.Regexp, len(secretFields))
+	for _, field := range secretFields {
+		patterns[field] = regexp.MustCompile(`(?s)<` + field + `>.*?</` + field + `>`)
+	}
+	return patterns
+}()
+
+// 隐藏xml中的签名和密钥等敏感字段
+func redactSecrets(x XML) XML {
+	s := string(x)
+	for field, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "<"+field+">***</"+field+">")
+	}
+	return XML(s)
 }
 
 // 创建微信支付账号
@@ -150,19 +507,391 @@ func NewAccount(appID string, mchID string, apiKey string, isSanbox bool) *Accou
 	}
 }
 
+// 创建微信支付账号并校验参数格式
+func NewAccountChecked(appID string, mchID string, apiKey string, isSanbox bool) (*Account, error) {
+	account := NewAccount(appID, mchID, apiKey, isSanbox)
+	if err := account.Validate(); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// 公众账号ID
+func (a *Account) AppID() string {
+	return a.appID
+}
+
+// 商户号
+func (a *Account) MchID() string {
+	return a.mchID
+}
+
+// 是否为沙箱环境
+func (a *Account) IsSandbox() bool {
+	return a.isSandbox
+}
+
+// 校验账号参数格式: appID以wx开头, mchID为数字, apiKey为32位
+func (a *Account) Validate() error {
+	if !strings.HasPrefix(a.appID, "wx") {
+		return fmt.Errorf("wechat: invalid appID %q, must start with wx", a.appID)
+	}
+	if len(a.mchID) == 0 || !isDigits(a.mchID) {
+		return fmt.Errorf("wechat: invalid mchID %q, must be numeric", a.mchID)
+	}
+	if len(a.apiKey) != 32 {
+		return errors.New("wechat: invalid apiKey, must be 32 characters")
+	}
+	return nil
+}
+
+// 创建服务商模式的微信支付账号, appID和mchID为服务商的参数, subAppID可以为空
+func NewSubMerchantAccount(appID, mchID, apiKey, subAppID, subMchID string, isSanbox bool) *Account {
+	account := NewAccount(appID, mchID, apiKey, isSanbox)
+	account.subAppID = subAppID
+	account.subMchID = subMchID
+	return account
+}
+
+// 创建携带商户证书(p12格式)的微信支付账号
+func NewAccountWithCert(appID string, mchID string, apiKey string, certData []byte, isSanbox bool) *Account {
+	account := NewAccount(appID, mchID, apiKey, isSanbox)
+	account.certData = certData
+	return account
+}
+
+// 创建携带商户证书(PEM格式)的微信支付账号
+func NewAccountWithPEM(appID string, mchID string, apiKey string, certPEM, keyPEM []byte, isSanbox bool) *Account {
+	account := NewAccount(appID, mchID, apiKey, isSanbox)
+	account.certPEM = certPEM
+	account.keyPEM = keyPEM
+	return account
+}
+
+// 设置RSA签名使用的商户私钥(PEM格式, 支持PKCS#1和PKCS#8)
+func (a *Account) SetPrivateKey(keyPEM []byte) error {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return errors.New("wechat: invalid private key PEM")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		a.privateKey = key
+		return nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return errors.New("wechat: private key is not RSA")
+	}
+	a.privateKey = rsaKey
+	return nil
+}
+
+// 隐藏apiKey, 只保留首尾各2个字符
+func maskKey(key string) string {
+	if len(key) <= 4 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:2] + strings.Repeat("*", len(key)-4) + key[len(key)-2:]
+}
+
+// 格式化账号信息, 隐藏apiKey和证书内容
+func (a *Account) String() string {
+	return fmt.Sprintf("Account{appID:%s mchID:%s apiKey:%s certData:[%d bytes] certPEM:[%d bytes] keyPEM:[%d bytes] isSandbox:%t}",
+		a.appID, a.mchID, maskKey(a.apiKey), len(a.certData), len(a.certPEM), len(a.keyPEM), a.isSandbox)
+}
+
+func (a *Account) GoString() string {
+	return "&wechat." + a.String()
+}
+
+// 格式化客户端信息, 隐藏账号中的敏感信息
+func (c *Client) String() string {
+	return fmt.Sprintf("Client{account:%s signType:%s httpConnectTimeoutMs:%d httpReadTimeoutMs:%d baseURL:%s}",
+		c.account, c.signType, c.httpConnectTimeoutMs, c.httpReadTimeoutMs, c.baseURL)
+}
+
+func (c *Client) GoString() string {
+	return "&wechat." + c.String()
+}
+
 // 创建微信支付客户端
 func NewClient(account *Account) *Client {
-	return &Client{
+	c := &Client{
 		account:              account,
 		signType:             MD5,
 		httpConnectTimeoutMs: 2000,
 		httpReadTimeoutMs:    1000,
+		baseURL:              DefaultBaseURL,
+	}
+	c.resetHTTPClient()
+	return c
+}
+
+// 创建指定签名类型的微信支付客户端
+func NewClientWithSignType(account *Account, signType string) (*Client, error) {
+	c := NewClient(account)
+	if err := c.SetSignType(signType); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 设置签名类型, 支持MD5、HMAC-SHA256和RSA
+// 使用RSA签名时账号必须已通过SetPrivateKey设置商户私钥, 否则返回ErrMissingKey
+func (c *Client) SetSignType(signType string) error {
+	switch signType {
+	case MD5, HMACSHA256:
+	case RSA:
+		if c.account.privateKey == nil {
+			return ErrMissingKey
+		}
+	default:
+		return ErrInvalidSignType
+	}
+	c.signType = signType
+	return nil
+}
+
+// 返回使用指定签名类型的客户端副本, 原客户端不受影响, 签名类型无效时返回ErrInvalidSignType
+// 副本与原客户端共享同一个账号和http连接
+func (c *Client) WithSignType(signType string) (*Client, error) {
+	clone := &Client{
+		lastLatency:          atomic.LoadInt64(&c.lastLatency),
+		account:              c.account,
+		httpConnectTimeoutMs: c.httpConnectTimeoutMs,
+		httpReadTimeoutMs:    c.httpReadTimeoutMs,
+		httpClient:           c.httpClient,
+		defaultClient:        c.defaultClient,
+		certClient:           c.certClient,
+		baseURL:              c.baseURL,
+		fallbackHosts:        c.fallbackHosts,
+		logger:               c.logger,
+		maxRetries:           c.maxRetries,
+		metrics:              c.metrics,
+		nonceGenerator:       c.nonceGenerator,
+		queryCache:           c.queryCache,
+		header:               c.header,
+	}
+	if err := clone.SetSignType(signType); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// 设置连接超时时间(毫秒)
+func (c *Client) SetConnectTimeout(ms int) error {
+	if ms <= 0 {
+		return ErrInvalidTimeout
 	}
+	c.httpConnectTimeoutMs = ms
+	c.resetHTTPClient()
+	return nil
 }
 
-// 用时间戳生成随机字符串
+// 设置读取超时时间(毫秒)
+func (c *Client) SetReadTimeout(ms int) error {
+	if ms <= 0 {
+		return ErrInvalidTimeout
+	}
+	c.httpReadTimeoutMs = ms
+	c.resetHTTPClient()
+	return nil
+}
+
+// 用crypto/rand生成32位随机字符串
 func nonceStr() string {
-	return strconv.FormatInt(time.Now().UTC().UnixNano(), 10)
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// 随机源不可用时退化为时间戳
+		return strconv.FormatInt(time.Now().UTC().UnixNano(), 10)
+	}
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// 设置附加到每个请求的请求头, 如User-Agent, 设置Content-Type时覆盖默认值
+// 修改的是请求头的副本, 不影响通过WithSignType复制的客户端
+func (c *Client) SetHeader(key, value string) {
+	header := c.header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set(key, value)
+	c.header = header
+}
+
+// 设置随机字符串生成器, 生成的字符串不能超过32位
+func (c *Client) SetNonceGenerator(f func() string) {
+	c.nonceGenerator = f
+}
+
+// 生成随机字符串, 默认使用crypto/rand
+func (c *Client) nonce() string {
+	if c.nonceGenerator != nil {
+		return c.nonceGenerator()
+	}
+	return nonceStr()
+}
+
+// 根据超时设置创建http客户端
+func (c *Client) newHTTPClient() *http.Client {
+	return newTimeoutClient(c.httpConnectTimeoutMs, c.httpReadTimeoutMs)
+}
+
+// 创建带连接超时与读取超时(毫秒)的http客户端
+func newTimeoutClient(connectTimeoutMs, readTimeoutMs int) *http.Client {
+	connectTimeout := time.Duration(connectTimeoutMs) * time.Millisecond
+	readTimeout := time.Duration(readTimeoutMs) * time.Millisecond
+	dialer := &net.Dialer{Timeout: connectTimeout}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			ResponseHeaderTimeout: readTimeout,
+		},
+		// 整个请求的超时时间为连接超时与读取超时之和
+		Timeout: connectTimeout + readTimeout,
+	}
+}
+
+// 按当前的超时设置重新创建http客户端, 并关闭旧客户端的空闲连接
+func (c *Client) resetHTTPClient() {
+	if c.defaultClient != nil {
+		c.defaultClient.CloseIdleConnections()
+	}
+	c.defaultClient = c.newHTTPClient()
+	c.certClient = new(certClient)
+}
+
+// 设置api域名, 如"https://api2.mch.weixin.qq.com"
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// 设置备用api域名, 如BackupBaseURL
+// 无法连接主域名(拨号失败或域名解析失败)时, 依次使用备用域名重发同一个已签名的请求
+// 请求可能已到达服务端的错误(如读取超时、TLS校验失败)和业务失败(FAIL)不会切换域名, 避免重复扣款
+func (c *Client) SetFallbackHosts(hosts ...string) {
+	c.fallbackHosts = make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		c.fallbackHosts = append(c.fallbackHosts, strings.TrimSuffix(host, "/"))
+	}
+}
+
+// 根据是否沙箱环境选择api地址, 并替换为客户端配置的域名
+func (c *Client) apiURL(url, sandboxURL string) string {
+	if c.account.isSandbox {
+		url = sandboxURL
+	}
+	return c.baseURL + strings.TrimPrefix(url, DefaultBaseURL)
+}
+
+// 设置网络错误或服务端错误时的最大重试次数, 默认不重试
+func (c *Client) SetMaxRetries(n int) {
+	if n < 0 {
+		n = 0
+	}
+	c.maxRetries = n
+}
+
+// 设置请求耗时回调, 每次调用api后执行
+func (c *Client) SetMetrics(f MetricsFunc) {
+	c.metrics = f
+}
+
+// 最近一次请求的耗时
+func (c *Client) LastLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.lastLatency))
+}
+
+// 设置请求日志
+func (c *Client) SetLogger(logger Logger) {
+	c.logger = logger
+}
+
+// 设置自定义的http客户端, 设置后超时配置不再生效
+func (c *Client) SetHTTPClient(h *http.Client) {
+	c.httpClient = h
+	c.certClient = new(certClient)
+}
+
+// 获取发送请求使用的http客户端
+func (c *Client) client() *http.Client {
+	if c.httpClient != nil {
+		return c.httpClient
+	}
+	return c.defaultClient
+}
+
+// 解析商户证书, 优先使用PEM格式的证书, p12格式的证书密码默认为商户号
+func (a *Account) certificate() (tls.Certificate, error) {
+	if len(a.certPEM) > 0 {
+		return tls.X509KeyPair(a.certPEM, a.keyPEM)
+	}
+	if len(a.certData) == 0 {
+		return tls.Certificate{}, ErrMissingCert
+	}
+	key, cert, err := pkcs12.Decode(a.certData, a.mchID)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}, nil
+}
+
+// 用于下载数据流的http客户端, 不限制读取响应体的总耗时, 以免大文件被截断
+// 连接超时和读取响应头的超时仍然生效, 之后由调用方通过ctx控制
+func (c *Client) streamClient() *http.Client {
+	h := *c.client()
+	h.Timeout = 0
+	return &h
+}
+
+// 携带商户证书的http客户端, 首次使用时创建
+type certClient struct {
+	once sync.Once
+	h    *http.Client
+	err  error
+}
+
+// 返回携带商户证书的http客户端, 在所有需要证书的请求间复用
+func (c *Client) certHTTPClient() (*http.Client, error) {
+	cc := c.certClient
+	cc.once.Do(func() {
+		cc.h, cc.err = c.newCertHTTPClient()
+	})
+	return cc.h, cc.err
+}
+
+// 创建携带商户证书的http客户端
+func (c *Client) newCertHTTPClient() (*http.Client, error) {
+	cert, err := c.account.certificate()
+	if err != nil {
+		return nil, err
+	}
+	// 复制一份http客户端, 避免修改自定义客户端的配置
+	h := *c.client()
+	var transport *http.Transport
+	switch t := h.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return nil, errors.New("wechat: custom transport does not support client certificates")
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	h.Transport = transport
+	return &h, nil
 }
 
 // 生成支付签名参数
@@ -176,13 +905,43 @@ func (c *Client) PayParams(nonceStr, prepayID string) Map {
 		SetInt64("timeStamp", time.Now().Unix())
 }
 
-// 签名
-func (c *Client) Sign(params Map) string {
+// 生成带签名的JSAPI支付参数(wx.requestPayment)
+func (c *Client) JSAPIPayParams(prepayID string) Map {
+	params := c.PayParams(c.nonce(), prepayID)
+	return params.SetString("paySign", c.Sign(params))
+}
+
+// 生成WeixinJSBridge.invoke('getBrandWCPayRequest', ...)所需的参数
+// 包含appId、timeStamp、nonceStr、package、signType和paySign, 注意与JSSDK的wx.chooseWXPay使用的timestamp大小写不同
+func (c *Client) BrandWCPayRequest(prepayID string) Map {
+	return c.JSAPIPayParams(prepayID)
+}
+
+// 生成带签名的APP支付参数
+func (c *Client) AppPayParams(prepayID string) Map {
+	params := make(Map)
+	params = params.SetString("appid", c.account.appID).
+		SetString("partnerid", c.account.mchID).
+		SetString("prepayid", prepayID).
+		SetString("package", "Sign=WXPay").
+		SetString("noncestr", c.nonce()).
+		SetInt64("timestamp", time.Now().Unix())
+	return c.SetSign(params)
+}
+
+// 拼接签名原串, 与微信的签名规则一致:
+//  1. 参数按key的ASCII码从小到大排序
+//  2. 值为空或只包含空白的参数不参与签名
+//  3. sign字段不参与签名, 不区分大小写
+//  4. value去除首尾的空白后拼接, 不做URL编码
+//
+// Map不是并发安全的, 签名期间params不能被其他goroutine修改, 需要并发设置参数时使用SyncMap
+func signBase(params Map) string {
 	// 创建切片
 	var keys = make([]string, 0, len(params))
 	// 遍历签名参数
 	for k := range params {
-		if k != "sign" { // 排除sign字段
+		if !strings.EqualFold(k, "sign") { // 排除sign字段
 			keys = append(keys, k)
 		}
 	}
@@ -193,16 +952,77 @@ func (c *Client) Sign(params Map) string {
 	//创建字符缓冲
 	var buf bytes.Buffer
 	for _, k := range keys {
-		if len(params.GetString(k)) > 0 {
+		v := strings.TrimSpace(params.GetString(k))
+		if len(v) > 0 {
+			if buf.Len() > 0 {
+				buf.WriteString(`&`)
+			}
 			buf.WriteString(k)
 			buf.WriteString(`=`)
-			buf.WriteString(params.GetString(k))
-			buf.WriteString(`&`)
+			buf.WriteString(v)
 		}
 	}
+	return buf.String()
+}
+
+// 签名原串, 用于排查签名错误, 注意其中包含apiKey
+func (c *Client) SignSource(params Map) string {
+	base := signBase(params)
 	// 加入apiKey作加密密钥
-	buf.WriteString(`key=`)
-	buf.WriteString(c.account.apiKey)
+	if len(base) == 0 {
+		return "key=" + c.account.apiKey
+	}
+	return base + "&key=" + c.account.apiKey
+}
+
+// 签名
+// 签名类型和RSA私钥在SetSignType时已校验, 只有RSA签名运算本身失败时返回空字符串
+func (c *Client) Sign(params Map) string {
+	sign, _ := c.signWith(params, c.signType)
+	return sign
+}
+
+// 签名并将结果保存到sign字段, 已有的sign字段会先被清除, 字段值首尾的空白会被去除
+// RSA签名运算失败时不设置sign字段
+func (c *Client) SetSign(params Map) Map {
+	if signed, err := c.setSignWith(params, c.signType); err == nil {
+		return signed
+	}
+	return params
+}
+
+// 使用指定的签名类型签名并保存到sign字段
+// 签名前去除所有字段值首尾的空白(如粘贴的body末尾的换行), 保证发送的值与签名的值一致
+func (c *Client) setSignWith(params Map, signType string) (Map, error) {
+	for k := range params {
+		if strings.EqualFold(k, "sign") {
+			delete(params, k)
+		}
+	}
+	trimValues(params)
+	sign, err := c.signWith(params, signType)
+	if err != nil {
+		return nil, err
+	}
+	return params.SetString("sign", sign), nil
+}
+
+// 去除所有字段值首尾的空白
+// 微信按去除空白后的值校验签名, 对所有字段生效, 包括body、attach、detail等用户输入的字段
+func trimValues(params Map) {
+	for k, v := range params {
+		params[k] = strings.TrimSpace(v)
+	}
+}
+
+// 使用指定的签名类型签名
+func (c *Client) signWith(params Map, signType string) (string, error) {
+	// RSA签名不拼接apiKey
+	if signType == RSA {
+		return c.signRSA(signBase(params))
+	}
+
+	source := []byte(c.SignSource(params))
 
 	var (
 		dataMd5    [16]byte
@@ -210,80 +1030,661 @@ func (c *Client) Sign(params Map) string {
 		str        string
 	)
 
-	switch c.signType {
+	switch signType {
 	case MD5:
-		dataMd5 = md5.Sum(buf.Bytes())
+		dataMd5 = md5.Sum(source)
 		str = hex.EncodeToString(dataMd5[:]) //需转换成切片
 	case HMACSHA256:
 		h := hmac.New(sha256.New, []byte(c.account.apiKey))
-		h.Write(buf.Bytes())
+		h.Write(source)
 		dataSha256 = h.Sum(nil)
 		str = hex.EncodeToString(dataSha256[:])
+	default:
+		return "", ErrInvalidSignType
 	}
 
-	return strings.ToUpper(str)
+	return strings.ToUpper(str), nil
 }
 
-// 统一下单
-func (c *Client) UnifiedOrder(params Map) (Map, error) {
-	// 指定url
-	var url string
-	if c.account.isSandbox {
-		url = SandboxUnifiedOrderUrl
-	} else {
-		url = UnifiedOrderUrl
+// 使用商户私钥进行SHA256withRSA签名, 结果为base64编码
+func (c *Client) signRSA(base string) (string, error) {
+	if c.account.privateKey == nil {
+		return "", ErrMissingKey
 	}
-	// 填充account中的参数
-	params = params.SetString("appid", c.account.appID).
+	digest := sha256.Sum256([]byte(base))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.account.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// 校验签名, 优先使用params中sign_type指定的签名类型
+// 与微信的签名规则一致, 所有非空的非sign字段都参与签名, 因此微信新增并参与签名的字段不影响校验;
+// 若响应中的字段在传输中被增删(如代理添加的字段), 签名原串与微信不一致, 校验将失败
+func (c *Client) VerifySign(params Map) bool {
+	signType := c.signType
+	if t := params.GetString("sign_type"); len(t) > 0 {
+		signType = t
+	}
+	return c.verifySignWith(params, signType)
+}
+
+// 使用指定的签名类型校验签名
+// 微信的响应不一定携带sign_type, 调用api时应使用请求的签名类型校验响应
+// 商户私钥只用于请求签名, 微信使用apiKey对响应签名, 因此RSA签名的请求按响应中的sign_type(默认MD5)校验
+func (c *Client) verifySignWith(params Map, signType string) bool {
+	if len(params.GetString("sign")) == 0 {
+		return false
+	}
+	if signType == RSA {
+		signType = MD5
+		if params.GetString("sign_type") == HMACSHA256 {
+			signType = HMACSHA256
+		}
+	}
+	sign, err := c.signWith(params, signType)
+	return err == nil && strings.EqualFold(params.GetString("sign"), sign)
+}
+
+// 检查必填参数
+func requireParams(params Map, keys ...string) error {
+	for _, k := range keys {
+		if len(params.GetString(k)) == 0 {
+			return fmt.Errorf("wechat: missing required param %s", k)
+		}
+	}
+	return nil
+}
+
+// 检查参数中至少存在一个
+func requireAnyParam(params Map, keys ...string) error {
+	for _, k := range keys {
+		if len(params.GetString(k)) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("wechat: one of params %s is required", strings.Join(keys, ","))
+}
+
+// 校验支付结果通知地址, 必须为外网可访问的绝对地址, 建议使用https
+func validateNotifyURL(params Map) error {
+	notifyURL := params.GetString("notify_url")
+	u, err := url.Parse(notifyURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("wechat: invalid notify_url %q, must be an absolute http(s) url", notifyURL)
+	}
+	return nil
+}
+
+// 在params的副本上填充account中的参数并签名, 不修改调用方的params
+func (c *Client) fillRequestData(params Map) (Map, error) {
+	return c.fillRequestDataWith(params, c.signType)
+}
+
+// 在params的副本上填充account中的参数并使用指定的签名类型签名
+func (c *Client) fillRequestDataWith(params Map, signType string) (Map, error) {
+	params = params.Clone()
+	// 服务商模式下携带子商户参数
+	if len(c.account.subAppID) > 0 {
+		params.SetString("sub_appid", c.account.subAppID)
+	}
+	if len(c.account.subMchID) > 0 {
+		params.SetString("sub_mch_id", c.account.subMchID)
+	}
+	params.SetString("appid", c.account.appID).
 		SetString("mch_id", c.account.mchID).
-		SetString("nonce_str", nonceStr()).
-		SetString("sign_type", c.signType).
-		SetString("sign", c.Sign(params))
-	// 发送下单请求
-	h := &http.Client{}
-	response, err := h.Post(url, bodyType, strings.NewReader(params.ToXML().String()))
+		SetString("nonce_str", c.nonce()).
+		SetString("sign_type", signType)
+	return c.setSignWith(params, signType)
+}
+
+// 发送请求并解析结果
+func (c *Client) postXML(ctx context.Context, url string, params Map) (Map, error) {
+	return c.doPostXML(ctx, c.client(), url, params)
+}
+
+// 使用指定的http客户端发送请求, 返回原始响应
+func (c *Client) doPost(ctx context.Context, h *http.Client, url string, params Map) ([]byte, error) {
+	return c.doPostBody(ctx, h, url, params.ToXML())
+}
+
+// 使用指定的http客户端发送已序列化的xml, 返回原始响应
+func (c *Client) doPostBody(ctx context.Context, h *http.Client, url string, body XML) ([]byte, error) {
+	start := time.Now()
+	raw, err := c.sendFailover(ctx, h, url, body)
+	c.record(url, body, raw, time.Since(start), err)
+	return raw, err
+}
+
+// 记录请求耗时并调用metrics和logger回调
+func (c *Client) record(url string, body XML, raw []byte, dur time.Duration, err error) {
+	atomic.StoreInt64(&c.lastLatency, int64(dur))
+	if c.metrics != nil {
+		c.metrics(strings.TrimPrefix(url, c.baseURL), dur, err)
+	}
+	if c.logger != nil {
+		c.logger.Log(url, redactSecrets(body), redactSecrets(XML(raw)), err)
+	}
+}
+
+// 发送请求体并读取响应, 无法连接主域名时依次切换到备用域名
+func (c *Client) sendFailover(ctx context.Context, h *http.Client, url string, body XML) ([]byte, error) {
+	response, err := c.openFailover(ctx, h, url, body)
 	if err != nil {
 		return nil, err
 	}
+	defer response.Body.Close()
 	// 读取结果
-	_res, err := ioutil.ReadAll(response.Body)
-	response.Body.Close()
+	return ioutil.ReadAll(response.Body)
+}
+
+// 发送请求体, 无法连接主域名时依次切换到备用域名, 由调用方关闭响应体
+func (c *Client) openFailover(ctx context.Context, h *http.Client, url string, body XML) (*http.Response, error) {
+	response, err := c.open(ctx, h, url, body)
+	if !strings.HasPrefix(url, c.baseURL) {
+		return response, err
+	}
+	path := strings.TrimPrefix(url, c.baseURL)
+	for _, host := range c.fallbackHosts {
+		if err == nil || !isDialError(err) || ctx.Err() != nil {
+			break
+		}
+		response, err = c.open(ctx, h, host+path, body)
+	}
+	return response, err
+}
+
+// 判断是否为网络错误, http状态码错误不属于网络错误
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// 判断是否为建立连接时的错误, 此时请求一定没有发送到服务端
+func isDialError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// 发送请求体, 返回状态码为200的响应, 由调用方关闭响应体
+func (c *Client) open(ctx context.Context, h *http.Client, url string, body XML) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(string(body)))
 	if err != nil {
 		return nil, err
 	}
-	res := XML(_res).Compact().ToMap()
-	return res, nil
+	request.Header.Set("Content-Type", bodyType)
+	for k, v := range c.header {
+		request.Header[k] = v
+	}
+	response, err := h.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		_res, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBodyLen))
+		return nil, newHTTPStatusError(response.StatusCode, _res)
+	}
+	return response, nil
+}
+
+// 响应体片段的最大长度
+const maxErrorBodyLen = 256
+
+// http状态码不为200时返回的错误
+type HTTPStatusError struct {
+	StatusCode int    // http状态码
+	Body       string // 响应体片段
+}
+
+func newHTTPStatusError(statusCode int, body []byte) *HTTPStatusError {
+	if len(body) > maxErrorBodyLen {
+		body = body[:maxErrorBodyLen]
+	}
+	return &HTTPStatusError{StatusCode: statusCode, Body: string(body)}
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("wechat: unexpected http status %d, body: %s", e.StatusCode, e.Body)
+}
+
+// 判断错误是否可以重试, 只有网络错误和服务端错误(5xx)可以重试
+func isRetryable(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return isNetworkError(err)
+}
+
+// 发送请求并解析结果, 遇到网络错误或服务端错误时按指数退避重试
+// 只能用于幂等的请求
+func (c *Client) postXMLRetry(ctx context.Context, url string, params Map) (Map, error) {
+	for i := 0; ; i++ {
+		res, err := c.postXML(ctx, url, params)
+		if err == nil || i >= c.maxRetries || !isRetryable(err) || ctx.Err() != nil {
+			return res, err
+		}
+		select {
+		case <-time.After(retryBackoff << uint(i)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// 使用指定的http客户端发送请求并解析结果, 使用请求的签名类型校验响应签名
+func (c *Client) doPostXML(ctx context.Context, h *http.Client, url string, params Map) (Map, error) {
+	_res, err := c.doPost(ctx, h, url, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.parseResponse(_res, requestSignType(params))
+}
+
+// 使用指定的http客户端发送请求并解析结果, 用于响应不带签名的接口(企业付款、红包、沙箱秘钥和交易保障)
+func (c *Client) doPostXMLUnsigned(ctx context.Context, h *http.Client, url string, params Map) (Map, error) {
+	_res, err := c.doPost(ctx, h, url, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.parseResponse(_res, unsignedResponse)
+}
+
+// 请求使用的签名类型, 未携带sign_type时微信按MD5处理
+func requestSignType(params Map) string {
+	if t := params.GetString("sign_type"); len(t) > 0 {
+		return t
+	}
+	return MD5
+}
+
+// 判断响应是否为xml格式, 允许以xml声明开头
+func isXMLBody(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("<?xml")) {
+		if i := bytes.Index(data, []byte("?>")); i >= 0 {
+			data = bytes.TrimSpace(data[i+2:])
+		}
+	}
+	return bytes.HasPrefix(data, []byte("<xml>"))
+}
+
+// 响应体为空或不是xml格式时返回的错误, 可以用errors.Is(err, ErrEmptyResponse)判断
+type MalformedResponseError struct {
+	Raw []byte // 原始响应体
+}
+
+func (e *MalformedResponseError) Error() string {
+	raw := e.Raw
+	if len(raw) > maxErrorBodyLen {
+		raw = raw[:maxErrorBodyLen]
+	}
+	return fmt.Sprintf("%v: %q", ErrEmptyResponse, raw)
+}
+
+func (e *MalformedResponseError) Unwrap() error {
+	return ErrEmptyResponse
+}
+
+// 响应不带签名的接口传给parseResponse的签名类型
+const unsignedResponse = ""
+
+// 解析响应, 使用signType校验签名并检查业务结果
+// 通信成功(return_code为SUCCESS)的响应必须带有正确的签名, 防止签名被删除后绕过校验
+// signType为unsignedResponse时不校验签名, 只能用于微信不对响应签名的接口
+func (c *Client) parseResponse(raw []byte, signType string) (Map, error) {
+	if !isXMLBody(raw) {
+		return nil, &MalformedResponseError{Raw: raw}
+	}
+	res := XML(raw).ToMap()
+	// 微信只对通信成功的响应签名
+	if signType != unsignedResponse && res.GetString("return_code") == SUCCESS && !c.verifySignWith(res, signType) {
+		return nil, ErrInvalidSign
+	}
+	// 业务失败时同时返回响应, 便于调用方查看详情
+	return res, checkResult(res)
+}
+
+// 发送请求并解析结果, 同时返回原始响应
+func (c *Client) postXMLRaw(ctx context.Context, url string, params Map) (Map, XML, error) {
+	raw, err := c.doPost(ctx, c.client(), url, params)
+	if err != nil {
+		return nil, "", err
+	}
+	res, err := c.parseResponse(raw, requestSignType(params))
+	return res, XML(raw), err
+}
+
+// 统一下单
+func (c *Client) UnifiedOrder(params Map) (Map, error) {
+	return c.UnifiedOrderContext(context.Background(), params)
+}
+
+// 统一下单(支持取消)
+func (c *Client) UnifiedOrderContext(ctx context.Context, params Map) (Map, error) {
+	if err := validateTotalFee(params); err != nil {
+		return nil, err
+	}
+	if err := validateNotifyURL(params); err != nil {
+		return nil, err
+	}
+	// 指定url
+	url := c.apiURL(UnifiedOrderUrl, SandboxUnifiedOrderUrl)
+	// 发送下单请求, 相同的out_trade_no重复下单是幂等的
+	params, err := c.fillRequestData(params)
+	if err != nil {
+		return nil, err
+	}
+	return c.postXMLRetry(ctx, url, params)
 }
 
 // 查询订单
 func (c *Client) OrderQuery(params Map) (Map, error) {
+	return c.OrderQueryContext(context.Background(), params)
+}
+
+// 查询订单(支持取消)
+func (c *Client) OrderQueryContext(ctx context.Context, params Map) (Map, error) {
 	// 指定url
-	var url string
-	if c.account.isSandbox {
-		url = SandboxOrderQueryUrl
-	} else {
-		url = OrderQueryUrl
+	url := c.apiURL(OrderQueryUrl, SandboxOrderQueryUrl)
+	outTradeNo := params.GetString("out_trade_no")
+	params, err := c.fillRequestData(params)
+	if err != nil {
+		return nil, err
 	}
-	// 填充account中的数据
-	params = params.SetString("appid", c.account.appID).
-		SetString("mch_id", c.account.mchID).
-		SetString("nonce_str", nonceStr()).
-		SetString("sign_type", c.signType).
-		SetString("sign", c.Sign(params))
-	// 发送查询订单请求
-	h := &http.Client{}
-	response, err := h.Post(url, bodyType, strings.NewReader(params.ToXML().String()))
+	if c.queryCache == nil || outTradeNo == "" {
+		// 发送查询订单请求
+		return c.postXMLRetry(ctx, url, params)
+	}
+	return c.queryCache.do(ctx, outTradeNo, func() (Map, error) {
+		// 共享的请求不随发起查询的调用方取消, 耗时由http客户端的超时限制
+		return c.postXMLRetry(context.WithoutCancel(ctx), url, params)
+	})
+}
+
+// 申请退款(需要商户证书)
+func (c *Client) Refund(params Map) (Map, error) {
+	// 指定url
+	url := c.apiURL(RefundUrl, SandboxRefundUrl)
+	h, err := c.certHTTPClient()
 	if err != nil {
 		return nil, err
 	}
-	// 读取结果
-	_res, err := ioutil.ReadAll(response.Body)
-	response.Body.Close()
+	// 发送退款请求
+	params, err = c.fillRequestData(params)
+	if err != nil {
+		return nil, err
+	}
+	return c.doPostXML(context.Background(), h, url, params)
+}
+
+// 获取沙箱签名秘钥
+func (c *Client) GetSandboxSignKey() (string, error) {
+	params := make(Map)
+	params = params.SetString("mch_id", c.account.mchID).
+		SetString("nonce_str", c.nonce())
+	// 获取沙箱签名秘钥的请求只支持MD5签名
+	params, err := c.setSignWith(params, MD5)
+	if err != nil {
+		return "", err
+	}
+	// 响应中的sandbox_signkey即签名秘钥, 响应本身不带签名
+	res, err := c.doPostXMLUnsigned(context.Background(), c.client(), c.apiURL(SandboxGetSignKeyUrl, SandboxGetSignKeyUrl), params)
+	if err != nil {
+		return "", err
+	}
+	return res.GetString("sandbox_signkey"), nil
+}
+
+// 沙箱环境下将apiKey替换为沙箱签名秘钥, 之后的请求都使用沙箱签名秘钥签名
+func (c *Client) UseSandboxSignKey() error {
+	if !c.account.isSandbox {
+		return nil
+	}
+	key, err := c.GetSandboxSignKey()
+	if err != nil {
+		return err
+	}
+	c.account.apiKey = key
+	return nil
+}
+
+// 关闭订单
+func (c *Client) CloseOrder(params Map) (Map, error) {
+	if err := requireParams(params, "out_trade_no"); err != nil {
+		return nil, err
+	}
+	// 指定url
+	url := c.apiURL(CloseOrderUrl, SandboxCloseOrderUrl)
+	// 发送关闭订单请求
+	params, err := c.fillRequestData(params)
+	if err != nil {
+		return nil, err
+	}
+	return c.postXML(context.Background(), url, params)
+}
+
+// 查询退款
+func (c *Client) RefundQuery(params Map) (Map, error) {
+	err := requireAnyParam(params, "refund_id", "out_refund_no", "transaction_id", "out_trade_no")
+	if err != nil {
+		return nil, err
+	}
+	// 指定url
+	url := c.apiURL(RefundQueryUrl, SandboxRefundQueryUrl)
+	// 发送查询退款请求
+	params, err = c.fillRequestData(params)
+	if err != nil {
+		return nil, err
+	}
+	return c.postXML(context.Background(), url, params)
+}
+
+// Native支付下单, 返回二维码链接code_url
+func (c *Client) NativeOrder(params Map) (string, error) {
+	if err := requireParams(params, "product_id"); err != nil {
+		return "", err
+	}
+	res, err := c.UnifiedOrder(params.Clone().SetString("trade_type", TradeTypeNative))
+	if err != nil {
+		return "", err
+	}
+	if !res.ContainsKey("code_url") {
+		return "", errors.New("wechat: code_url not found in response")
+	}
+	return res.GetString("code_url"), nil
+}
+
+// H5支付下单, 返回支付跳转链接mweb_url
+func (c *Client) H5Order(params Map) (string, error) {
+	if err := requireParams(params, "scene_info"); err != nil {
+		return "", err
+	}
+	res, err := c.UnifiedOrder(params.Clone().SetString("trade_type", TradeTypeMWeb))
+	if err != nil {
+		return "", err
+	}
+	if !res.ContainsKey("mweb_url") {
+		return "", errors.New("wechat: mweb_url not found in response")
+	}
+	return res.GetString("mweb_url"), nil
+}
+
+// 撤销订单(需要商户证书)
+// 响应中recall为Y时表示需要继续调用撤销
+func (c *Client) Reverse(params Map) (Map, error) {
+	if err := requireAnyParam(params, "transaction_id", "out_trade_no"); err != nil {
+		return nil, err
+	}
+	// 指定url
+	url := c.apiURL(ReverseUrl, SandboxReverseUrl)
+	h, err := c.certHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	// 发送撤销订单请求
+	params, err = c.fillRequestData(params)
+	if err != nil {
+		return nil, err
+	}
+	return c.doPostXML(context.Background(), h, url, params)
+}
+
+// 统一下单, 同时返回未经处理的响应
+func (c *Client) UnifiedOrderRaw(params Map) (Map, XML, error) {
+	if err := validateTotalFee(params); err != nil {
+		return nil, "", err
+	}
+	if err := validateNotifyURL(params); err != nil {
+		return nil, "", err
+	}
+	url := c.apiURL(UnifiedOrderUrl, SandboxUnifiedOrderUrl)
+	params, err := c.fillRequestData(params)
+	if err != nil {
+		return nil, "", err
+	}
+	return c.postXMLRaw(context.Background(), url, params)
+}
+
+// 查询订单, 同时返回未经处理的响应
+func (c *Client) OrderQueryRaw(params Map) (Map, XML, error) {
+	url := c.apiURL(OrderQueryUrl, SandboxOrderQueryUrl)
+	params, err := c.fillRequestData(params)
+	if err != nil {
+		return nil, "", err
+	}
+	return c.postXMLRaw(context.Background(), url, params)
+}
+
+// 下单, 订单已存在时改为查询订单
+// 统一下单返回以下错误代码时视为订单已存在:
+//
+//	ORDERPAID         订单已支付
+//	OUT_TRADE_NO_USED 商户订单号重复
+func (c *Client) EnsureOrder(params Map) (Map, error) {
+	outTradeNo := params.GetString("out_trade_no")
+	res, err := c.UnifiedOrder(params)
+	if wxErr, ok := err.(*WxError); ok && (wxErr.IsErrCode("ORDERPAID") || wxErr.IsErrCode("OUT_TRADE_NO_USED")) {
+		query := make(Map)
+		return c.OrderQuery(query.SetString("out_trade_no", outTradeNo))
+	}
+	return res, err
+}
+
+// 付款码支付
+// 返回错误代码USERPAYING时用户正在输入密码, 调用方需要轮询OrderQuery确认支付结果
+func (c *Client) MicroPay(params Map) (Map, error) {
+	err := requireParams(params, "auth_code", "body", "out_trade_no", "total_fee", "spbill_create_ip")
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTotalFee(params); err != nil {
+		return nil, err
+	}
+	// 指定url
+	url := c.apiURL(MicroPayUrl, SandboxMicroPayUrl)
+	// 发送付款码支付请求
+	params, err = c.fillRequestData(params)
 	if err != nil {
 		return nil, err
 	}
-	res := XML(_res).Compact().ToMap()
-	return res, nil
+	return c.postXML(context.Background(), url, params)
+}
+
+// 交易保障, 上报接口调用耗时等信息
+func (c *Client) Report(params Map) (Map, error) {
+	err := requireParams(params, "interface_url", "execute_time", "return_code", "result_code", "user_ip", "time")
+	if err != nil {
+		return nil, err
+	}
+	// 指定url
+	url := c.apiURL(ReportUrl, SandboxReportUrl)
+	// 发送上报请求, 交易保障接口的响应不带签名
+	params, err = c.fillRequestData(params)
+	if err != nil {
+		return nil, err
+	}
+	return c.doPostXMLUnsigned(context.Background(), c.client(), url, params)
+}
+
+// 将Native支付模式一的长链接转换为短链接
+// 签名时使用原始的long_url, 发送时long_url需要URL编码
+func (c *Client) ShortURL(longURL string) (string, error) {
+	params := make(Map)
+	params, err := c.fillRequestData(params.SetString("long_url", longURL))
+	if err != nil {
+		return "", err
+	}
+	params.SetString("long_url", url.QueryEscape(longURL))
+	res, err := c.postXML(context.Background(), c.apiURL(ShortURLUrl, SandboxShortURLUrl), params)
+	if err != nil {
+		return "", err
+	}
+	return res.GetString("short_url"), nil
+}
+
+// 通过付款码查询openid
+// 付款码无效或过期时返回*WxError, 错误代码为AUTH_CODE_INVALID或AUTHCODEEXPIRE
+func (c *Client) AuthCodeToOpenID(authCode string) (string, error) {
+	params := make(Map)
+	params, err := c.fillRequestData(params.SetString("auth_code", authCode))
+	if err != nil {
+		return "", err
+	}
+	res, err := c.postXML(context.Background(), c.apiURL(AuthCodeToOpenIDUrl, SandboxAuthCodeToOpenIDUrl), params)
+	if err != nil {
+		return "", err
+	}
+	return res.GetString("openid"), nil
+}
+
+// 轮询查询订单直到交易状态为终态或ctx结束
+// 终态为SUCCESS、REFUND、CLOSED、REVOKED和PAYERROR, NOTPAY和USERPAYING时继续轮询
+// interval为查询间隔, 必须大于0
+func (c *Client) WaitForPayment(ctx context.Context, params Map, interval time.Duration) (Map, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		res, err := c.OrderQueryContext(ctx, params)
+		if err != nil {
+			return res, err
+		}
+		if IsFinalTradeState(res.GetString("trade_state")) {
+			return res, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return res, ctx.Err()
+		}
+	}
+}
+
+// 检查与微信支付的连通性和apiKey是否正确
+// 通过查询一个不存在的订单实现, 返回经过签名的业务失败(如ORDERNOTEXIST)时说明连通性和秘钥均正常,
+// 返回签名错误(return_code为FAIL或响应签名校验失败)时说明apiKey错误
+func (c *Client) Ping() error {
+	// out_trade_no最长32位
+	nonce := c.nonce()
+	if len(nonce) > 16 {
+		nonce = nonce[:16]
+	}
+	params := make(Map)
+	_, err := c.OrderQuery(params.SetString("out_trade_no", "PING"+nonce))
+	var wxErr *WxError
+	if errors.As(err, &wxErr) && wxErr.ReturnCode == SUCCESS {
+		return nil
+	}
+	return err
 }
 
 // =======================
@@ -0,0 +1,114 @@
+package wechat
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testAppID  = "wx2421b1c4370ec43b"
+	testMchID  = "10000100"
+	testAPIKey = "192006250b4c09247ec02edce69f6a2d"
+)
+
+// 创建连接到测试服务器的客户端
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	c.SetBaseURL(srv.URL)
+	return c
+}
+
+// 读取测试服务器收到的请求参数
+// 在handler的goroutine中调用, 不能使用t.Fatal, 读取失败时返回空Map
+func readRequest(t *testing.T, r *http.Request) Map {
+	t.Helper()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Error(err)
+		return Map{}
+	}
+	return XML(body).ToMap()
+}
+
+// 以signType签名后写入响应, 模拟微信返回的签名响应
+func writeSigned(w http.ResponseWriter, res Map, signType string) {
+	signer := NewClient(NewAccount(testAppID, testMchID, testAPIKey, false))
+	res, err := signer.setSignWith(res.Clone(), signType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte(res.ToXML()))
+}
+
+// 成功的响应
+func successResponse() Map {
+	return Map{"return_code": SUCCESS, "result_code": SUCCESS, "appid": testAppID, "mch_id": testMchID, "nonce_str": "5K8264ILTKCH16CQ2502SI8ZNMTM67VS"}
+}
+
+// 生成测试用的自签名商户证书和私钥(PEM格式)
+func newTestCertPEM(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: testMchID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return certPEM, keyPEM
+}
+
+// 创建带商户证书并连接到测试服务器的客户端
+func newTestCertClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	certPEM, keyPEM := newTestCertPEM(t)
+	c := NewClient(NewAccountWithPEM(testAppID, testMchID, testAPIKey, certPEM, keyPEM, false))
+	c.SetBaseURL(srv.URL)
+	return c
+}
+
+// 创建要求客户端证书的TLS测试服务器, 返回使用account并信任该服务器的客户端
+func newTestTLSClient(t *testing.T, account *Account, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	c := NewClient(account)
+	c.SetHTTPClient(srv.Client())
+	c.SetBaseURL(srv.URL)
+	return c
+}
+
+// 创建v3接口的测试服务器, 返回服务器地址
+func newTestV3Server(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
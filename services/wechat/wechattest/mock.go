@@ -0,0 +1,89 @@
+// 用于测试的微信支付模拟服务器
+package wechattest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/mind1949/wxpay_demo/services/wechat"
+)
+
+// 模拟微信支付api的服务器, 使用配置的apiKey对响应签名, 使客户端的签名校验能够通过
+// 配合Client.SetBaseURL使用:
+//
+//	server := wechattest.NewMockServer(apiKey)
+//	defer server.Close()
+//	server.Handle("/pay/unifiedorder", wechat.Map{"return_code": "SUCCESS", ...})
+//	client.SetBaseURL(server.URL)
+type MockServer struct {
+	*httptest.Server
+
+	account   *wechat.Account
+	mu        sync.Mutex
+	responses map[string]wechat.Map   // 各api路径的响应
+	requests  map[string][]wechat.Map // 各api路径收到的请求
+}
+
+// 创建并启动模拟服务器
+func NewMockServer(apiKey string) *MockServer {
+	s := &MockServer{
+		account:   wechat.NewAccount("", "", apiKey, false),
+		responses: make(map[string]wechat.Map),
+		requests:  make(map[string][]wechat.Map),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// 注册api路径的响应, 如"/pay/unifiedorder"
+// 响应会使用其中的sign_type签名, 未指定时使用请求的sign_type(默认MD5, RSA签名的请求使用MD5)
+func (s *MockServer) Handle(path string, response wechat.Map) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[path] = response
+}
+
+// api路径收到的所有请求
+func (s *MockServer) Requests(path string) []wechat.Map {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]wechat.Map(nil), s.requests[path]...)
+}
+
+func (s *MockServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request := wechat.XML(body).ToMap()
+	s.mu.Lock()
+	s.requests[r.URL.Path] = append(s.requests[r.URL.Path], request)
+	response, ok := s.responses[r.URL.Path]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	res := s.sign(response, request.GetString("sign_type"))
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write([]byte(res.ToXML()))
+}
+
+// 复制响应并使用apiKey签名, 响应未指定sign_type时使用requestSignType
+// 与Client的校验规则一致, 微信只使用apiKey对响应签名, RSA签名的请求的响应使用MD5签名
+func (s *MockServer) sign(response wechat.Map, requestSignType string) wechat.Map {
+	signType := response.GetString("sign_type")
+	if signType == "" {
+		signType = requestSignType
+	}
+	client := wechat.NewClient(s.account)
+	if signType == wechat.HMACSHA256 {
+		client.SetSignType(wechat.HMACSHA256)
+	}
+	return client.SetSign(response.Clone())
+}
@@ -0,0 +1,108 @@
+package wechattest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/mind1949/wxpay_demo/services/wechat"
+)
+
+const testAPIKey = "192006250b4c09247ec02edce69f6a2d"
+
+// 针对模拟服务器完成一次统一下单
+func unifiedOrder(t *testing.T, client *wechat.Client) {
+	t.Helper()
+	server := NewMockServer(testAPIKey)
+	defer server.Close()
+	response := wechat.Map{
+		"return_code": wechat.SUCCESS,
+		"result_code": wechat.SUCCESS,
+		"trade_type":  "NATIVE",
+		"prepay_id":   "wx201410272009395522657a690389285100",
+		"code_url":    "weixin://wxpay/bizpayurl/up?pr=NwY5Mz9&groupid=00",
+	}
+	server.Handle("/pay/unifiedorder", response)
+
+	client.SetBaseURL(server.URL)
+	res, err := client.UnifiedOrder(wechat.Map{
+		"body":             "腾讯充值中心-QQ会员充值",
+		"out_trade_no":     "20150806125346",
+		"total_fee":        "88",
+		"spbill_create_ip": "123.12.12.123",
+		"notify_url":       "http://www.weixin.qq.com/wxpay/pay.php",
+		"trade_type":       "NATIVE",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetString("prepay_id") != "wx201410272009395522657a690389285100" {
+		t.Errorf("unexpected response: %v", res)
+	}
+	if !client.VerifySign(res) {
+		t.Error("response sign invalid")
+	}
+
+	// 签名在副本上进行, 注册的响应不变
+	if response.ContainsKey("sign") {
+		t.Error("registered response was modified")
+	}
+
+	requests := server.Requests("/pay/unifiedorder")
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.GetString("out_trade_no") != "20150806125346" || req.GetString("mch_id") != "10000100" {
+		t.Errorf("unexpected request: %v", req)
+	}
+	// RSA签名也是确定的, 三种签名类型都可以重新计算后比较
+	if req.GetString("sign") == "" || req.GetString("sign") != client.Sign(req) {
+		t.Error("request sign invalid")
+	}
+}
+
+// 创建指定签名类型的客户端
+func newClient(t *testing.T, account *wechat.Account, signType string) *wechat.Client {
+	t.Helper()
+	client, err := wechat.NewClientWithSignType(account, signType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestMockServerUnifiedOrder(t *testing.T) {
+	unifiedOrder(t, newClient(t, wechat.NewAccount("wx2421b1c4370ec43b", "10000100", testAPIKey, false), wechat.MD5))
+}
+
+func TestMockServerUnifiedOrderHMACSHA256(t *testing.T) {
+	unifiedOrder(t, newClient(t, wechat.NewAccount("wx2421b1c4370ec43b", "10000100", testAPIKey, false), wechat.HMACSHA256))
+}
+
+func TestMockServerUnifiedOrderRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := wechat.NewAccount("wx2421b1c4370ec43b", "10000100", testAPIKey, false)
+	if err := account.SetPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})); err != nil {
+		t.Fatal(err)
+	}
+	unifiedOrder(t, newClient(t, account, wechat.RSA))
+}
+
+func TestMockServerNotFound(t *testing.T) {
+	server := NewMockServer(testAPIKey)
+	defer server.Close()
+	client := wechat.NewClient(wechat.NewAccount("wx2421b1c4370ec43b", "10000100", testAPIKey, false))
+	client.SetBaseURL(server.URL)
+	if _, err := client.OrderQuery(wechat.Map{"out_trade_no": "20150806125346"}); err == nil {
+		t.Error("want error for unregistered path")
+	}
+	if n := len(server.Requests("/pay/orderquery")); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
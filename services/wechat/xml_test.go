@@ -0,0 +1,117 @@
+package wechat
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestToMapNested(t *testing.T) {
+	m := XML(`<xml><return_code>SUCCESS</return_code><empty></empty><closed/>` +
+		`<detail><goods_id>1001</goods_id><body><![CDATA[iPhone 6s]]></body></detail></xml>`).ToMap()
+	want := Map{"return_code": SUCCESS, "empty": "", "closed": "", "goods_id": "1001", "body": "iPhone 6s"}
+	if len(m) != len(want) {
+		t.Fatalf("ToMap() = %v, want %v", m, want)
+	}
+	for k, v := range want {
+		if got, ok := m[k]; !ok || got != v {
+			t.Errorf("%s = %q (present %t), want %q", k, got, ok, v)
+		}
+	}
+	if m.ContainsKey("xml") || m.ContainsKey("detail") {
+		t.Errorf("root or non-leaf element captured: %v", m)
+	}
+}
+
+func TestToMapRootName(t *testing.T) {
+	// 根元素不一定叫xml
+	m := XML(`<root><xml>1</xml></root>`).ToMap()
+	if len(m) != 1 || m.GetString("xml") != "1" {
+		t.Errorf("ToMap() = %v", m)
+	}
+}
+
+func TestToMapBlankValue(t *testing.T) {
+	m := XML("<xml>\n  <blank>\n</blank>\n  <no_text></no_text>\n  <trade_type> NATIVE </trade_type>\n</xml>").ToMap()
+	if v, ok := m["blank"]; !ok || v != "" {
+		t.Errorf("blank = %q (present %t), want empty", v, ok)
+	}
+	// 没有内容的元素不能继承上一个元素的值
+	if v, ok := m["no_text"]; !ok || v != "" {
+		t.Errorf("no_text = %q (present %t), want empty", v, ok)
+	}
+	if m.GetString("trade_type") != "NATIVE" || len(m) != 3 {
+		t.Errorf("ToMap() = %v", m)
+	}
+}
+
+func TestToXMLEscapesCDATAEnd(t *testing.T) {
+	m := Map{"attach": "a]]><sign>forged</sign>b", "body": "<b>&amp;</b>"}
+	got := m.ToXML().ToMap()
+	if len(got) != 2 || got.GetString("attach") != m.GetString("attach") || got.GetString("body") != m.GetString("body") {
+		t.Errorf("round trip = %v, want %v", got, m)
+	}
+	if got.ContainsKey("sign") {
+		t.Error("value injected a sign element")
+	}
+}
+
+func TestToXMLSorted(t *testing.T) {
+	m := Map{"total_fee": "1", "appid": testAppID, "body": "test", "mch_id": testMchID, "nonce_str": "abc"}
+	want := XML(`<xml><appid><![CDATA[wx2421b1c4370ec43b]]></appid><body><![CDATA[test]]></body>` +
+		`<mch_id><![CDATA[10000100]]></mch_id><nonce_str><![CDATA[abc]]></nonce_str><total_fee><![CDATA[1]]></total_fee></xml>`)
+	for i := 0; i < 20; i++ {
+		if got := m.ToXML(); got != want {
+			t.Fatalf("ToXML() = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestXMLString(t *testing.T) {
+	x := Map{"body": "a<b", "total_fee": "1"}.ToXML()
+	want := "<xml>\n  <body>a&lt;b</body>\n  <total_fee>1</total_fee>\n</xml>"
+	if got := x.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := XML(x.String()).Compact().ToMap(); got.GetString("body") != "a<b" || got.GetString("total_fee") != "1" {
+		t.Errorf("compacted pretty xml = %v", got)
+	}
+	if got := XML("<xml><a>").String(); got != "<xml><a>" {
+		t.Errorf("String() of malformed xml = %q, want it unchanged", got)
+	}
+}
+
+func TestToMapCharset(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"no declaration", `<xml><body>中文</body></xml>`, "中文"},
+		{"utf-8", `<?xml version="1.0" encoding="UTF-8"?><xml><body>中文</body></xml>`, "中文"},
+		// 中文的GBK编码为D6D0 CEC4
+		{"gbk", "<?xml version=\"1.0\" encoding=\"GBK\"?><xml><body>\xd6\xd0\xce\xc4</body></xml>", "中文"},
+		{"gb2312", "<?xml version=\"1.0\" encoding=\"gb2312\"?><xml><body>\xb2\xe2\xca\xd4</body></xml>", "测试"},
+	}
+	for _, tt := range tests {
+		if got := XML(tt.in).ToMap().GetString("body"); got != tt.want {
+			t.Errorf("%s: body = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	// 不支持的编码不解析内容
+	if m := XML(`<?xml version="1.0" encoding="BIG5"?><xml><body>x</body></xml>`).ToMap(); len(m) != 0 {
+		t.Errorf("ToMap(BIG5) = %v, want empty", m)
+	}
+}
+
+func TestParseResponseGBK(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml; charset=GBK")
+		w.Write([]byte("<?xml version=\"1.0\" encoding=\"GBK\"?><xml><return_code>FAIL</return_code><return_msg>\xd6\xd0\xce\xc4</return_msg></xml>"))
+	})
+	_, err := c.OrderQuery(Map{"out_trade_no": "1217752501201407033233368018"})
+	var wxErr *WxError
+	if !errors.As(err, &wxErr) || wxErr.ReturnMsg != "中文" {
+		t.Errorf("err = %v, want return_msg decoded from GBK", err)
+	}
+}